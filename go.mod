@@ -10,7 +10,9 @@ require (
 	github.com/rs/zerolog v1.34.0
 	github.com/spf13/cobra v1.10.1
 	go.mau.fi/whatsmeow v0.0.0-20250930215512-38f9aaa3ba7c
+	golang.org/x/text v0.29.0
 	google.golang.org/protobuf v1.36.9
+	rsc.io/qr v0.2.0
 )
 
 require (
@@ -39,7 +41,5 @@ require (
 	golang.org/x/net v0.44.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
 	golang.org/x/term v0.35.0 // indirect
-	golang.org/x/text v0.29.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
-	rsc.io/qr v0.2.0 // indirect
 )
@@ -0,0 +1,620 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"rsc.io/qr"
+)
+
+// defaultAccount is the alias of an account linked without choosing one. It
+// keeps the configured message store and media paths, so the account linked
+// before aliases existed still finds its messages.
+const defaultAccount = "default"
+
+// statePairing is the list_accounts state of an account still being linked
+const statePairing = "pairing"
+
+// validAlias is what an account alias may look like, as it ends up in file names
+var validAlias = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// accountsTable maps aliases to the devices in the whatsmeow session database
+const accountsTable = `CREATE TABLE IF NOT EXISTS multichat_accounts (
+	alias TEXT PRIMARY KEY,
+	jid   TEXT NOT NULL UNIQUE
+)`
+
+// account is one linked WhatsApp device with its own connection and message store
+type account struct {
+	alias  string
+	config WhatsAppConfig
+	client *whatsmeow.Client
+	store  *messageStore
+	conn   *connectionSupervisor
+
+	// verified remembers the JIDs of phone numbers found on WhatsApp
+	verifiedMu sync.Mutex
+	verified   map[string]types.JID
+
+	// adminFetches holds the requests for group admins that are in flight
+	adminFetchMu sync.Mutex
+	adminFetches map[types.JID]*adminFetch
+}
+
+// pairing is an account being linked with add_account, which becomes a full
+// account once the phone accepts it
+type pairing struct {
+	account *account
+	cancel  context.CancelFunc
+}
+
+// newAccount opens the message store of an account and creates its client
+func newAccount(alias string, config WhatsAppConfig, device *store.Device) (*account, error) {
+	store, err := newMessageStore(config.MessageDB)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create message store for account %q: %w", alias, err)
+	}
+
+	a := &account{
+		alias:  alias,
+		config: config,
+		client: whatsmeow.NewClient(device, nil),
+		store:  store,
+		conn:   newConnectionSupervisor(),
+
+		verified:     make(map[string]types.JID),
+		adminFetches: make(map[types.JID]*adminFetch),
+	}
+	a.client.EnableAutoReconnect = false
+	a.client.AddEventHandler(a.handleEvent)
+	return a, nil
+}
+
+// accountConfig returns the paths an account keeps its messages and media at.
+// Accounts other than the default one get their own next to the configured ones.
+func accountConfig(config WhatsAppConfig, alias string) WhatsAppConfig {
+	if alias == defaultAccount {
+		return config
+	}
+	ext := filepath.Ext(config.MessageDB)
+	config.MessageDB = strings.TrimSuffix(config.MessageDB, ext) + "-" + alias + ext
+	config.MediaDir = filepath.Join(config.MediaDir, alias)
+	return config
+}
+
+// validateAlias checks that an alias can be used for a new account
+func validateAlias(alias string) error {
+	if !validAlias.MatchString(alias) {
+		return fmt.Errorf("invalid account alias %q, use up to 32 lowercase letters, digits, - and _", alias)
+	}
+	return nil
+}
+
+// start connects the account and keeps it connected until stop is called.
+// A failed first attempt is retried in the background like a dropped connection.
+func (a *account) start() {
+	go a.supervise()
+
+	a.conn.setState(stateConnecting, nil)
+	if err := a.client.Connect(); err != nil {
+		log.Warn().Err(err).Str("account", a.alias).Msg("Failed to connect to WhatsApp, retrying in the background")
+		a.conn.lost(err)
+		return
+	}
+	log.Info().Str("account", a.alias).Msg("WhatsApp connected successfully")
+}
+
+// stop disconnects the account and closes its message store
+func (a *account) stop() {
+	a.conn.shutdown()
+	a.client.Disconnect()
+	a.recordConnection(false)
+	if err := a.store.Close(); err != nil {
+		log.Error().Err(err).Str("account", a.alias).Msg("Failed to close message store")
+	}
+}
+
+// isConnected reports whether the account is connected to WhatsApp
+func (a *account) isConnected() bool {
+	return a.client.IsConnected()
+}
+
+// unlink removes the device from the WhatsApp account, deletes its session and
+// stops the account. Unlinking tells the phone to drop the device, which needs a
+// connection; if the phone already removed it, WhatsApp says so on connecting and
+// the session is deleted all the same. An error means the device is still linked.
+func (a *account) unlink(ctx context.Context) error {
+	jid := a.client.Store.ID.ToNonAD()
+	// Keep the supervisor from reconnecting while logging out
+	a.conn.shutdown()
+	defer a.stop()
+
+	loggedOut, err := a.awaitLogin(ctx)
+	if err == nil && !loggedOut {
+		err = a.client.Logout(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to unlink device, it is still linked: %w", err)
+	}
+
+	// stop can't record this once the session is gone, as the device has no JID left
+	if err := a.store.setConnectionState(ctx, jid.String(), false, time.Now()); err != nil {
+		log.Error().Err(err).Msg("Failed to record connection state")
+	}
+	return nil
+}
+
+// awaitLogin connects the account if it isn't already and waits for WhatsApp to
+// accept its session, for up to loginConnectTimeout. It reports true if WhatsApp
+// says the device was unlinked instead, in which case whatsmeow deletes the session.
+func (a *account) awaitLogin(ctx context.Context) (loggedOut bool, err error) {
+	if a.client.IsLoggedIn() {
+		return false, nil
+	}
+
+	result := make(chan bool, 1)
+	handler := a.client.AddEventHandler(func(evt interface{}) {
+		var loggedOut bool
+		switch evt.(type) {
+		case *events.Connected:
+		case *events.LoggedOut:
+			loggedOut = true
+		default:
+			return
+		}
+		select {
+		case result <- loggedOut:
+		default:
+		}
+	})
+	defer a.client.RemoveEventHandler(handler)
+
+	if !a.client.IsConnected() {
+		if err := a.client.Connect(); err != nil {
+			return false, fmt.Errorf("failed to connect: %w", err)
+		}
+	}
+	select {
+	case loggedOut = <-result:
+		return loggedOut, nil
+	case <-time.After(loginConnectTimeout):
+		return false, fmt.Errorf("timed out connecting to WhatsApp")
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// linkedDevices returns the linked devices by alias. Devices without an alias
+// are registered on the way: the first as the default account, so a device
+// linked before aliases existed keeps its message store, others by phone number.
+func (w *WhatsAppMessenger) linkedDevices(ctx context.Context) (map[string]*store.Device, error) {
+	devices, err := w.container.GetAllDevices(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	rows, err := w.db.QueryContext(ctx, "SELECT alias, jid FROM multichat_accounts")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query accounts: %w", err)
+	}
+	defer rows.Close()
+
+	aliases := make(map[string]string)
+	for rows.Next() {
+		var alias, jid string
+		if err := rows.Scan(&alias, &jid); err != nil {
+			return nil, fmt.Errorf("failed to scan account: %w", err)
+		}
+		aliases[jid] = alias
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+
+	linked := make(map[string]*store.Device, len(devices))
+	var unnamed []*store.Device
+	for _, device := range devices {
+		if alias, ok := aliases[device.ID.String()]; ok {
+			linked[alias] = device
+		} else {
+			unnamed = append(unnamed, device)
+		}
+	}
+	for _, device := range unnamed {
+		alias := device.ID.User
+		if _, ok := linked[defaultAccount]; !ok {
+			alias = defaultAccount
+		}
+		if err := w.saveAlias(ctx, alias, *device.ID); err != nil {
+			return nil, err
+		}
+		linked[alias] = device
+	}
+	return linked, nil
+}
+
+// saveAlias registers the alias of a linked device, replacing whatever the
+// alias pointed at before
+func (w *WhatsAppMessenger) saveAlias(ctx context.Context, alias string, jid types.JID) error {
+	_, err := w.db.ExecContext(ctx, `
+		INSERT INTO multichat_accounts (alias, jid) VALUES (?, ?)
+		ON CONFLICT (alias) DO UPDATE SET jid = excluded.jid`,
+		alias, jid.String())
+	if err != nil {
+		return fmt.Errorf("failed to save account alias: %w", err)
+	}
+	return nil
+}
+
+// deleteAlias forgets the alias of an unlinked device
+func (w *WhatsAppMessenger) deleteAlias(ctx context.Context, alias string) error {
+	if _, err := w.db.ExecContext(ctx, "DELETE FROM multichat_accounts WHERE alias = ?", alias); err != nil {
+		return fmt.Errorf("failed to delete account alias: %w", err)
+	}
+	return nil
+}
+
+// resolveAccount returns the account a tool call is for. The alias can be left out
+// while a single account is linked.
+func (w *WhatsAppMessenger) resolveAccount(alias string) (*account, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if a, ok := w.accounts[alias]; ok {
+		return a, nil
+	}
+	if _, ok := w.pairing[alias]; ok {
+		return nil, fmt.Errorf("account %q is still being linked, check list_accounts", alias)
+	}
+
+	aliases := slices.Sorted(maps.Keys(w.accounts))
+	switch {
+	case len(aliases) == 0:
+		return nil, fmt.Errorf("no WhatsApp account is linked, add one with add_account")
+	case alias != "":
+		return nil, fmt.Errorf("unknown account %q, linked accounts: %s", alias, strings.Join(aliases, ", "))
+	case len(aliases) == 1:
+		return w.accounts[aliases[0]], nil
+	}
+	return nil, fmt.Errorf("several WhatsApp accounts are linked, choose one with the account argument: %s", strings.Join(aliases, ", "))
+}
+
+// listAccounts returns the linked accounts and those still being linked
+func (w *WhatsAppMessenger) listAccounts(ctx context.Context) ([]AccountStatus, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	accounts := []AccountStatus{}
+	for _, alias := range slices.Sorted(maps.Keys(w.accounts)) {
+		a := w.accounts[alias]
+		status := AccountStatus{
+			Alias:        alias,
+			JID:          a.client.Store.GetJID().ToNonAD().String(),
+			PushName:     a.client.Store.PushName,
+			Platform:     a.client.Store.Platform,
+			BusinessName: a.client.Store.BusinessName,
+			State:        a.conn.status().State,
+			Connected:    a.isConnected(),
+		}
+		_, lastSeen, err := a.store.connectionState(ctx, status.JID)
+		if err != nil {
+			return nil, err
+		}
+		status.LastSeen = lastSeen
+		accounts = append(accounts, status)
+	}
+	for _, alias := range slices.Sorted(maps.Keys(w.pairing)) {
+		accounts = append(accounts, AccountStatus{Alias: alias, State: statePairing})
+	}
+	return accounts, nil
+}
+
+// addAccount starts linking a new device under alias and returns the pairing
+// code to enter on phoneNumber, or a QR code image when no number is given.
+// Linking completes in the background once the phone accepts the device.
+func (w *WhatsAppMessenger) addAccount(ctx context.Context, alias, phoneNumber string) (code string, qrImage []byte, err error) {
+	if err := validateAlias(alias); err != nil {
+		return "", nil, err
+	}
+	if phoneNumber != "" {
+		if phoneNumber, err = normalizePhone(phoneNumber, w.config.DefaultCountryCode); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// Linking outlives the tool call, so it doesn't use the request context.
+	// The alias is reserved right away, so only one account can ever use it.
+	pairCtx, cancel := context.WithCancel(context.Background())
+	reservation := &pairing{cancel: cancel}
+	w.mu.Lock()
+	if _, ok := w.accounts[alias]; ok {
+		w.mu.Unlock()
+		cancel()
+		return "", nil, fmt.Errorf("account %q already exists", alias)
+	}
+	// Asking again for an account being linked starts over with fresh codes
+	if previous, ok := w.pairing[alias]; ok {
+		previous.cancel()
+	}
+	w.pairing[alias] = reservation
+	w.mu.Unlock()
+
+	var a *account
+	fail := func(err error) (string, []byte, error) {
+		cancel()
+		w.mu.Lock()
+		if w.pairing[alias] == reservation {
+			delete(w.pairing, alias)
+		}
+		w.mu.Unlock()
+		if a != nil {
+			a.stop()
+		}
+		return "", nil, err
+	}
+
+	a, err = newAccount(alias, accountConfig(w.config, alias), w.container.NewDevice())
+	if err != nil {
+		return fail(err)
+	}
+	w.mu.Lock()
+	reservation.account = a
+	w.mu.Unlock()
+
+	qrChan, err := a.client.GetQRChannel(pairCtx)
+	if err != nil {
+		return fail(fmt.Errorf("failed to get QR channel: %w", err))
+	}
+	if err := a.client.Connect(); err != nil {
+		return fail(fmt.Errorf("failed to connect: %w", err))
+	}
+
+	var evt whatsmeow.QRChannelItem
+	select {
+	case evt = <-qrChan:
+	case <-ctx.Done():
+		return fail(ctx.Err())
+	}
+	if evt.Event != "code" {
+		return fail(fmt.Errorf("login failed: %s", evt.Event))
+	}
+
+	if phoneNumber != "" {
+		code, err = a.client.PairPhone(pairCtx, phoneNumber, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+		if err != nil {
+			return fail(fmt.Errorf("failed to request pairing code: %w", err))
+		}
+	} else {
+		img, err := qr.Encode(evt.Code, qr.L)
+		if err != nil {
+			return fail(fmt.Errorf("failed to encode QR code: %w", err))
+		}
+		qrImage = img.PNG()
+	}
+
+	go w.finishPairing(pairCtx, a, qrChan)
+
+	log.Info().Str("account", alias).Msg("Linking WhatsApp account")
+	return code, qrImage, nil
+}
+
+// finishPairing waits for the phone to accept a device started by addAccount
+// and turns it into a connected account, or cleans up if linking fails
+func (w *WhatsAppMessenger) finishPairing(ctx context.Context, a *account, qrChan <-chan whatsmeow.QRChannelItem) {
+	err := fmt.Errorf("QR channel closed")
+	for evt := range qrChan {
+		// Later QR codes are never shown; add_account can be called again for fresh ones
+		if evt.Event == "code" {
+			continue
+		}
+		switch {
+		case evt == whatsmeow.QRChannelSuccess:
+			err = nil
+		case evt.Error != nil:
+			err = evt.Error
+		default:
+			err = fmt.Errorf("%s", evt.Event)
+		}
+		break
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Another add_account or remove_account for the alias cancelled this one
+	if p, ok := w.pairing[a.alias]; !ok || p.account != a {
+		a.stop()
+		return
+	}
+	w.pairing[a.alias].cancel()
+	delete(w.pairing, a.alias)
+
+	if err == nil {
+		err = w.saveAlias(context.Background(), a.alias, *a.client.Store.ID)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("account", a.alias).Msg("Failed to link WhatsApp account")
+		a.stop()
+		return
+	}
+
+	// whatsmeow reconnects by itself after pairing, and the Connected event
+	// moves the supervisor to connected
+	w.accounts[a.alias] = a
+	go a.supervise()
+	log.Info().Str("account", a.alias).Str("jid", a.client.Store.GetJID().ToNonAD().String()).Msg("Linked WhatsApp account")
+}
+
+// removeAccount unlinks an account's device and forgets it. Its message store
+// is kept, and is used again if an account is added under the same alias.
+func (w *WhatsAppMessenger) removeAccount(ctx context.Context, alias string) error {
+	w.mu.Lock()
+	if p, ok := w.pairing[alias]; ok {
+		// finishPairing cleans up once linking is cancelled
+		delete(w.pairing, alias)
+		w.mu.Unlock()
+		p.cancel()
+		return nil
+	}
+	a, ok := w.accounts[alias]
+	if !ok {
+		w.mu.Unlock()
+		return fmt.Errorf("unknown account %q", alias)
+	}
+	delete(w.accounts, alias)
+	w.mu.Unlock()
+
+	if err := a.unlink(ctx); err != nil {
+		// The device is still linked, so bring the account back rather than
+		// leave it unreachable until the next start
+		if restored, rerr := newAccount(alias, a.config, a.client.Store); rerr != nil {
+			log.Error().Err(rerr).Str("account", alias).Msg("Failed to restart WhatsApp account")
+		} else {
+			w.mu.Lock()
+			w.accounts[alias] = restored
+			w.mu.Unlock()
+			restored.start()
+		}
+		return err
+	}
+	if err := w.deleteAlias(ctx, alias); err != nil {
+		return err
+	}
+
+	log.Info().Str("account", alias).Msg("Removed WhatsApp account")
+	return nil
+}
+
+// registerAccountTools registers the MCP tools managing linked accounts
+func (w *WhatsAppMessenger) registerAccountTools(mcpServer *server.MCPServer) {
+	// list_accounts
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "list_accounts",
+		Description: "List the linked WhatsApp accounts with their alias, phone number, name and connection state. The alias is what the account argument of the other tools takes.",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, w.handleListAccounts)
+
+	// add_account
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "add_account",
+		Description: "Link another WhatsApp account. Returns a QR code to scan in WhatsApp > Linked devices > Link a device, or a pairing code to enter there when a phone number is given. The account shows up in list_accounts once the phone accepts it.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"alias": map[string]interface{}{
+					"type":        "string",
+					"description": "Name to refer to the account by, using lowercase letters, digits, - and _ (e.g. work)",
+				},
+				"phone_number": map[string]interface{}{
+					"type":        "string",
+					"description": "Phone number of the account, to link with a pairing code instead of a QR code",
+				},
+			},
+			Required: []string{"alias"},
+		},
+	}, w.handleAddAccount)
+
+	// remove_account
+	mcpServer.AddTool(mcp.Tool{
+		Name:        "remove_account",
+		Description: "Unlink a WhatsApp account and delete its session. Its stored messages are kept.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"alias": map[string]interface{}{
+					"type":        "string",
+					"description": "Alias of the account to remove",
+				},
+			},
+			Required: []string{"alias"},
+		},
+	}, w.handleRemoveAccount)
+}
+
+// accountHandler is the handler of a tool that acts on a single account
+type accountHandler func(a *account, ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error)
+
+// addTool registers a tool that acts on a single account. It adds the account
+// argument to the tool and resolves it before calling handler.
+func (w *WhatsAppMessenger) addTool(mcpServer *server.MCPServer, tool mcp.Tool, handler accountHandler) {
+	tool.InputSchema.Properties["account"] = map[string]interface{}{
+		"type":        "string",
+		"description": "Alias of the WhatsApp account to use, as listed by list_accounts. Can be left out while only one account is linked.",
+	}
+
+	mcpServer.AddTool(tool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		a, err := w.resolveAccount(request.GetString("account", ""))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return handler(a, ctx, request)
+	})
+}
+
+func (w *WhatsAppMessenger) handleListAccounts(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	accounts, err := w.listAccounts(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("list accounts failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(accounts)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (w *WhatsAppMessenger) handleAddAccount(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Alias       string `json:"alias"`
+		PhoneNumber string `json:"phone_number"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	code, qrImage, err := w.addAccount(ctx, args.Alias, args.PhoneNumber)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("add account failed: %v", err)), nil
+	}
+
+	if code != "" {
+		return mcp.NewToolResultText(fmt.Sprintf("Pairing code for account %s: %s\n"+
+			"On the phone open WhatsApp > Linked devices > Link a device > Link with phone number instead, and enter the code.", args.Alias, code)), nil
+	}
+	return mcp.NewToolResultImage(fmt.Sprintf("Scan this QR code within a minute in WhatsApp > Linked devices > Link a device to link account %s. "+
+		"Call add_account again for a new code if it expires.", args.Alias),
+		base64.StdEncoding.EncodeToString(qrImage), "image/png"), nil
+}
+
+func (w *WhatsAppMessenger) handleRemoveAccount(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Alias string `json:"alias"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	if err := w.removeAccount(ctx, args.Alias); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("remove account failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Account %s removed", args.Alias)), nil
+}
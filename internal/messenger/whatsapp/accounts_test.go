@@ -0,0 +1,148 @@
+package whatsapp
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow/proto/waAdv"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestAccountConfig(t *testing.T) {
+	config := WhatsAppConfig{
+		DeviceDB:  "/data/whatsapp.db",
+		MessageDB: "/data/messages.db",
+		MediaDir:  "/data/media",
+		UploadDir: "/uploads",
+	}
+	tests := []struct {
+		alias         string
+		wantMessageDB string
+		wantMediaDir  string
+	}{
+		{defaultAccount, "/data/messages.db", "/data/media"},
+		{"work", "/data/messages-work.db", "/data/media/work"},
+		{"15551234567", "/data/messages-15551234567.db", "/data/media/15551234567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			got := accountConfig(config, tt.alias)
+			if got.MessageDB != tt.wantMessageDB || got.MediaDir != tt.wantMediaDir {
+				t.Errorf("got message db %q and media dir %q, want %q and %q",
+					got.MessageDB, got.MediaDir, tt.wantMessageDB, tt.wantMediaDir)
+			}
+			// Accounts share the session database and upload directory
+			if got.DeviceDB != config.DeviceDB || got.UploadDir != config.UploadDir {
+				t.Errorf("got device db %q and upload dir %q, want them unchanged", got.DeviceDB, got.UploadDir)
+			}
+		})
+	}
+
+	// A message store without an extension still gets a name of its own
+	if got := accountConfig(WhatsAppConfig{MessageDB: "messages"}, "work").MessageDB; got != "messages-work" {
+		t.Errorf("got message db %q, want %q", got, "messages-work")
+	}
+}
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		valid bool
+	}{
+		{"work", true},
+		{"default", true},
+		{"my_phone-2", true},
+		{strings.Repeat("a", 32), true},
+		{"", false},
+		{strings.Repeat("a", 33), false},
+		{"Work", false},
+		{"work phone", false},
+		{"../work", false},
+		{"trabalho-é", false},
+	}
+
+	for _, tt := range tests {
+		if err := validateAlias(tt.alias); (err == nil) != tt.valid {
+			t.Errorf("validateAlias(%q) = %v, want valid %v", tt.alias, err, tt.valid)
+		}
+	}
+}
+
+func TestLinkedDevices(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	w, err := NewWhatsAppMessenger(WhatsAppConfig{
+		DeviceDB:  filepath.Join(dir, "whatsapp.db"),
+		MessageDB: filepath.Join(dir, "messages.db"),
+		MediaDir:  filepath.Join(dir, "media"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.container.Close() })
+
+	link := func(user string) *store.Device {
+		t.Helper()
+		device := w.container.NewDevice()
+		device.ID = &types.JID{User: user, Device: 12, Server: types.DefaultUserServer}
+		// Only the sizes of the signatures are checked when saving
+		device.Account = &waAdv.ADVSignedDeviceIdentity{
+			Details:             []byte{},
+			AccountSignature:    make([]byte, 64),
+			AccountSignatureKey: make([]byte, 32),
+			DeviceSignature:     make([]byte, 64),
+		}
+		if err := device.Save(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return device
+	}
+	check := func(step string, want map[string]types.JID) {
+		t.Helper()
+		linked, err := w.linkedDevices(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]types.JID, len(linked))
+		for alias, device := range linked {
+			got[alias] = *device.ID
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: got accounts %v, want %v", step, got, want)
+			return
+		}
+		for alias, jid := range want {
+			if got[alias] != jid {
+				t.Errorf("%s: got accounts %v, want %v", step, got, want)
+				return
+			}
+		}
+	}
+
+	// A device linked before aliases existed becomes the default account
+	first := link("15551234567")
+	check("first device", map[string]types.JID{defaultAccount: *first.ID})
+
+	// Devices with an alias keep it, and others are named after their number
+	work := link("15557654321")
+	if err := w.saveAlias(ctx, "work", *work.ID); err != nil {
+		t.Fatal(err)
+	}
+	other := link("351912345678")
+	want := map[string]types.JID{defaultAccount: *first.ID, "work": *work.ID, "351912345678": *other.ID}
+	check("more devices", want)
+
+	// The aliases were registered, so they hold once the default account is gone
+	if err := first.Delete(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.deleteAlias(ctx, defaultAccount); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, defaultAccount)
+	check("after removing the default account", want)
+}
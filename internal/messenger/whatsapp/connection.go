@@ -0,0 +1,376 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Connection states reported by connection_status. Logged out, replaced and
+// outdated are final: reconnecting can't fix them.
+const (
+	stateDisconnected = "disconnected"
+	stateConnecting   = "connecting"
+	stateConnected    = "connected"
+	stateReconnecting = "reconnecting"
+	stateBanned       = "temporarily_banned"
+	stateLoggedOut    = "logged_out"
+	stateReplaced     = "replaced"
+	stateOutdated     = "client_outdated"
+	stateStopped      = "stopped"
+)
+
+const (
+	// Reconnect delays double from reconnectMinDelay up to reconnectMaxDelay
+	reconnectMinDelay = 2 * time.Second
+	reconnectMaxDelay = 5 * time.Minute
+
+	// connectionHistorySize is how many state changes connection_status keeps
+	connectionHistorySize = 50
+
+	// connectionHeartbeat is how often a connected account records that it still
+	// is, so the status command can tell a running server from one that crashed
+	connectionHeartbeat = time.Minute
+)
+
+// connectionSupervisor tracks the state of the WhatsApp connection and brings it
+// back with exponential backoff when it drops. whatsmeow's own auto-reconnect is
+// turned off so there is a single place deciding when to retry.
+type connectionSupervisor struct {
+	mu         sync.Mutex
+	state      string
+	since      time.Time
+	lastError  string
+	errorAt    time.Time
+	reconnects int
+	attempts   int
+	nextRetry  time.Time
+	history    []ConnectionEvent
+
+	// banTimer reconnects when a temporary ban expires
+	banTimer *time.Timer
+
+	wake     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newConnectionSupervisor() *connectionSupervisor {
+	return &connectionSupervisor{
+		state: stateDisconnected,
+		since: time.Now(),
+		wake:  make(chan struct{}, 1),
+		stop:  make(chan struct{}),
+	}
+}
+
+// setState moves to a new state, recording it and err in the history
+func (s *connectionSupervisor) setState(state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setStateLocked(state, err)
+}
+
+func (s *connectionSupervisor) setStateLocked(state string, err error) {
+	if s.banTimer != nil {
+		s.banTimer.Stop()
+		s.banTimer = nil
+	}
+
+	now := time.Now()
+	event := ConnectionEvent{State: state, At: now}
+	if err != nil {
+		event.Error = err.Error()
+		s.lastError, s.errorAt = event.Error, now
+	}
+	if state != s.state {
+		s.since = now
+	}
+	s.state = state
+
+	s.history = append(s.history, event)
+	if len(s.history) > connectionHistorySize {
+		s.history = s.history[len(s.history)-connectionHistorySize:]
+	}
+}
+
+// connected records a successful (re)connection and resets the backoff
+func (s *connectionSupervisor) connected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == stateReconnecting || s.state == stateBanned {
+		s.reconnects++
+	}
+	s.attempts = 0
+	s.nextRetry = time.Time{}
+	s.setStateLocked(stateConnected, nil)
+}
+
+// banned records a temporary ban and reconnects once it expires, unless the
+// state has changed again by then
+func (s *connectionSupervisor) banned(err error, expire time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.setStateLocked(stateBanned, err)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(expire, func() {
+		s.mu.Lock()
+		current := s.banTimer == timer
+		s.mu.Unlock()
+		if current {
+			s.lost(fmt.Errorf("temporary ban expired"))
+		}
+	})
+	s.banTimer = timer
+}
+
+// lost records that the connection dropped and wakes the reconnect loop
+func (s *connectionSupervisor) lost(err error) {
+	s.mu.Lock()
+	if s.isFinal() {
+		s.mu.Unlock()
+		return
+	}
+	s.setStateLocked(stateReconnecting, err)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// isFinal reports whether the current state rules out reconnecting
+func (s *connectionSupervisor) isFinal() bool {
+	switch s.state {
+	case stateLoggedOut, stateReplaced, stateOutdated, stateStopped:
+		return true
+	}
+	return false
+}
+
+// nextDelay returns how long to wait before the next reconnect attempt, or
+// false if there is nothing to reconnect
+func (s *connectionSupervisor) nextDelay() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != stateReconnecting {
+		return 0, false
+	}
+
+	delay := reconnectMaxDelay
+	if s.attempts < 16 {
+		delay = min(reconnectMinDelay<<s.attempts, reconnectMaxDelay)
+	}
+	// Jitter keeps several instances from retrying in lockstep
+	delay += time.Duration(rand.Int64N(int64(delay / 5)))
+
+	s.attempts++
+	s.nextRetry = time.Now().Add(delay)
+	return delay, true
+}
+
+// failed records a reconnect attempt that didn't get through
+func (s *connectionSupervisor) failed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == stateReconnecting {
+		s.setStateLocked(stateReconnecting, err)
+	}
+}
+
+// recordError keeps an error that doesn't change the connection state
+func (s *connectionSupervisor) recordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastError, s.errorAt = err.Error(), time.Now()
+}
+
+// shutdown stops the reconnect loop for good
+func (s *connectionSupervisor) shutdown() {
+	s.stopOnce.Do(func() {
+		s.setState(stateStopped, nil)
+		close(s.stop)
+	})
+}
+
+// status returns a snapshot of the connection state
+func (s *connectionSupervisor) status() ConnectionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := ConnectionStatus{
+		State:          s.state,
+		Since:          s.since,
+		LastError:      s.lastError,
+		ReconnectCount: s.reconnects,
+		History:        append([]ConnectionEvent(nil), s.history...),
+	}
+	if s.lastError != "" {
+		errorAt := s.errorAt
+		status.LastErrorAt = &errorAt
+	}
+	if s.state == stateReconnecting {
+		status.Attempt = s.attempts
+		if !s.nextRetry.IsZero() {
+			next := s.nextRetry
+			status.NextRetry = &next
+		}
+	}
+	return status
+}
+
+// unavailableError explains why WhatsApp can't be used right now
+func (s *connectionSupervisor) unavailableError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch s.state {
+	case stateLoggedOut:
+		return fmt.Errorf("WhatsApp session was logged out (%s), link the account again with \"multichat login\" or add_account", s.lastError)
+	case stateReplaced:
+		return fmt.Errorf("WhatsApp session was taken over by another client using the same device, restart the server to reconnect")
+	case stateOutdated:
+		return fmt.Errorf("WhatsApp rejected this client version, update multichat")
+	case stateBanned:
+		return fmt.Errorf("not connected to WhatsApp: %s", s.lastError)
+	case stateReconnecting:
+		if wait := time.Until(s.nextRetry); wait > 0 {
+			return fmt.Errorf("not connected to WhatsApp, reconnecting (attempt %d in %s)", s.attempts, wait.Round(time.Second))
+		}
+		return fmt.Errorf("not connected to WhatsApp, reconnecting (attempt %d)", s.attempts)
+	}
+	return fmt.Errorf("not connected to WhatsApp")
+}
+
+// ensureConnected returns nil if WhatsApp can be used, or an error saying why not
+func (a *account) ensureConnected() error {
+	if a.isConnected() {
+		return nil
+	}
+	return a.conn.unavailableError()
+}
+
+// supervise reconnects whenever the supervisor is woken by a lost connection,
+// and records a heartbeat while connected, until the account is stopped
+func (a *account) supervise() {
+	heartbeat := time.NewTicker(connectionHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-a.conn.stop:
+			return
+		case <-heartbeat.C:
+			if a.isConnected() {
+				a.recordConnection(true)
+			}
+			continue
+		case <-a.conn.wake:
+		}
+
+		for {
+			delay, ok := a.conn.nextDelay()
+			if !ok {
+				break
+			}
+			log.Info().Str("account", a.alias).Dur("delay", delay).Msg("Reconnecting to WhatsApp")
+			select {
+			case <-a.conn.stop:
+				return
+			case <-time.After(delay):
+			}
+
+			err := a.client.Connect()
+			if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+				// The Connected event completes the reconnect, and if the server
+				// turns us away a Disconnected event wakes this loop again
+				break
+			}
+			log.Warn().Err(err).Str("account", a.alias).Msg("Failed to reconnect to WhatsApp")
+			a.conn.failed(err)
+		}
+	}
+}
+
+// handleConnectionEvent updates the connection state from whatsmeow's connection events
+func (a *account) handleConnectionEvent(evt interface{}) {
+	switch evt := evt.(type) {
+	case *events.Connected:
+		a.conn.connected()
+		a.recordConnection(true)
+		go a.renewPresenceSubscriptions()
+		go a.mergeStoredLIDs()
+	case *events.Disconnected:
+		log.Warn().Str("account", a.alias).Msg("Disconnected from WhatsApp")
+		a.recordConnection(false)
+		a.conn.lost(fmt.Errorf("connection closed by the server"))
+	case *events.KeepAliveTimeout:
+		log.Warn().Str("account", a.alias).Int("errors", evt.ErrorCount).Time("last_success", evt.LastSuccess).Msg("WhatsApp keepalive timed out")
+		// Like whatsmeow's auto-reconnect, give up on a connection that stays silent too long
+		if time.Since(evt.LastSuccess) > whatsmeow.KeepAliveMaxFailTime {
+			a.client.Disconnect()
+			a.recordConnection(false)
+			a.conn.lost(fmt.Errorf("keepalive failed %d times since %s", evt.ErrorCount, evt.LastSuccess.Format(time.RFC3339)))
+		}
+	case *events.ConnectFailure:
+		// whatsmeow drops the connection without a Disconnected event for these
+		log.Warn().Str("account", a.alias).Int("reason", int(evt.Reason)).Str("message", evt.Message).Msg("WhatsApp connection failed")
+		a.recordConnection(false)
+		a.conn.lost(fmt.Errorf("connect failure: %s %s", evt.Reason, evt.Message))
+	case *events.TemporaryBan:
+		log.Error().Str("account", a.alias).Str("ban", evt.String()).Msg("Temporarily banned by WhatsApp")
+		a.recordConnection(false)
+		a.conn.banned(errors.New(evt.String()), evt.Expire)
+	case *events.LoggedOut:
+		log.Error().Str("account", a.alias).Str("reason", evt.Reason.String()).Msg("Logged out of WhatsApp")
+		a.recordConnection(false)
+		a.conn.setState(stateLoggedOut, errors.New(evt.Reason.String()))
+	case *events.StreamReplaced:
+		log.Error().Str("account", a.alias).Msg("WhatsApp session replaced by another client")
+		a.recordConnection(false)
+		a.conn.setState(stateReplaced, fmt.Errorf("another client connected with the same device"))
+	case *events.ClientOutdated:
+		log.Error().Str("account", a.alias).Msg("WhatsApp rejected this client version")
+		a.recordConnection(false)
+		a.conn.setState(stateOutdated, fmt.Errorf("client version is outdated"))
+	case *events.StreamError:
+		log.Warn().Str("account", a.alias).Str("code", evt.Code).Msg("WhatsApp stream error")
+		a.conn.recordError(fmt.Errorf("stream error %s", evt.Code))
+	}
+}
+
+// registerConnectionTools registers the connection status MCP tool
+func (w *WhatsAppMessenger) registerConnectionTools(mcpServer *server.MCPServer) {
+	// connection_status
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "connection_status",
+		Description: "Get the state of the WhatsApp connection, the last connection error, how often it reconnected and the recent state history",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, (*account).handleConnectionStatus)
+}
+
+func (a *account) handleConnectionStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	status := a.conn.status()
+	status.Connected = a.isConnected()
+	if a.client != nil && a.client.Store.ID != nil {
+		status.JID = a.client.Store.ID.ToNonAD().String()
+	}
+
+	result, _ := json.Marshal(status)
+	return mcp.NewToolResultText(string(result)), nil
+}
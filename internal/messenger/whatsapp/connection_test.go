@@ -0,0 +1,56 @@
+package whatsapp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnectionSupervisorBan(t *testing.T) {
+	const expire = 20 * time.Millisecond
+	ban := errors.New("banned")
+
+	t.Run("expiry reconnects", func(t *testing.T) {
+		s := newConnectionSupervisor()
+		s.banned(ban, expire)
+		time.Sleep(5 * expire)
+		if state := s.status().State; state != stateReconnecting {
+			t.Errorf("state after the ban expired = %s, want %s", state, stateReconnecting)
+		}
+		select {
+		case <-s.wake:
+		default:
+			t.Error("ban expiry didn't wake the reconnect loop")
+		}
+	})
+
+	t.Run("shutdown stops the timer", func(t *testing.T) {
+		s := newConnectionSupervisor()
+		s.banned(ban, expire)
+		s.shutdown()
+		time.Sleep(5 * expire)
+		if state := s.status().State; state != stateStopped {
+			t.Errorf("state after stopping = %s, want %s", state, stateStopped)
+		}
+	})
+
+	t.Run("a second ban replaces the first", func(t *testing.T) {
+		s := newConnectionSupervisor()
+		s.banned(ban, expire)
+		s.banned(ban, time.Hour)
+		time.Sleep(5 * expire)
+		if state := s.status().State; state != stateBanned {
+			t.Errorf("state after the first ban would have expired = %s, want %s", state, stateBanned)
+		}
+	})
+
+	t.Run("reconnecting stops the timer", func(t *testing.T) {
+		s := newConnectionSupervisor()
+		s.banned(ban, expire)
+		s.connected()
+		time.Sleep(5 * expire)
+		if state := s.status().State; state != stateConnected {
+			t.Errorf("state after reconnecting = %s, want %s", state, stateConnected)
+		}
+	})
+}
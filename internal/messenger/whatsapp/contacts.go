@@ -0,0 +1,348 @@
+package whatsapp
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mau.fi/whatsmeow/types"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Fields search_contacts reports as matched
+const (
+	fieldFullName     = "full_name"
+	fieldFirstName    = "first_name"
+	fieldBusinessName = "business_name"
+	fieldPushName     = "push_name"
+	fieldPhoneNumber  = "phone_number"
+	fieldJID          = "jid"
+)
+
+const (
+	// recencyWeight is how much a contact we are talking to right now gains over
+	// one we never talked to, next to a best match quality of 1
+	recencyWeight = 0.2
+
+	// recencyHalfLife is how quickly the boost of a past interaction fades
+	recencyHalfLife = 30 * 24 * time.Hour
+
+	// minPhoneQuery is how many digits a query needs to match phone numbers by
+	// part, as shorter ones are in nearly every number
+	minPhoneQuery = 3
+)
+
+// searchContact is a contact gathered from the whatsmeow entries under its LID
+// and phone number, with each name field taken from whichever has it
+type searchContact struct {
+	Contact
+	names map[string]string
+}
+
+// searchContacts finds contacts whose names, phone number or JID match query.
+// Names match regardless of case and accents and with a few typos, and results
+// are ranked by how well they match and how recently we talked to the contact,
+// and only the best limit are returned.
+func (a *account) searchContacts(ctx context.Context, query string, limit int) ([]Contact, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	contacts, err := a.client.Store.Contacts.GetAllContacts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contacts: %w", err)
+	}
+	interactions, err := a.store.lastInteractions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// whatsmeow may know a contact under their LID, their phone number or both,
+	// so contacts are gathered under their phone number where it is known
+	byJID := make(map[types.JID]*searchContact)
+	for jid, info := range contacts {
+		pn := a.phoneJID(ctx, jid)
+		c, ok := byJID[pn]
+		if !ok {
+			c = &searchContact{Contact: Contact{JID: pn.String()}, names: make(map[string]string)}
+			if pn.Server == types.DefaultUserServer {
+				c.PhoneNumber = pn.User
+			}
+			if lid := a.userLID(ctx, pn); !lid.IsEmpty() {
+				c.LID = lid.String()
+			}
+			byJID[pn] = c
+		}
+		for field, name := range map[string]string{
+			fieldFullName:     info.FullName,
+			fieldFirstName:    info.FirstName,
+			fieldBusinessName: info.BusinessName,
+			fieldPushName:     info.PushName,
+		} {
+			if c.names[field] == "" {
+				c.names[field] = name
+			}
+		}
+	}
+
+	matcher := newContactMatcher(query, a.config.DefaultCountryCode)
+	if matcher.jid {
+		matcher.query = a.canonicalJID(ctx, query)
+	}
+
+	results := []Contact{}
+	for _, c := range byJID {
+		field, quality := matcher.match(c)
+		if quality == 0 && !matcher.empty {
+			continue
+		}
+
+		contact := c.Contact
+		contact.Name = c.displayName()
+		contact.MatchedField = field
+		contact.Score = quality
+		if last, ok := interactions[contact.JID]; ok {
+			contact.LastInteraction = &last
+			contact.Score += recencyWeight * math.Exp2(-time.Since(last).Hours()/recencyHalfLife.Hours())
+		}
+		// Scores only order the results, so a few digits are plenty
+		contact.Score = math.Round(contact.Score*1000) / 1000
+		results = append(results, contact)
+	}
+
+	slices.SortFunc(results, func(x, y Contact) int {
+		if c := cmp.Compare(y.Score, x.Score); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(strings.ToLower(x.Name), strings.ToLower(y.Name)); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.JID, y.JID)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// displayName is the name we know a contact by: the full or first name saved in
+// the address book, then the name a business registered, then the one they chose themselves
+func (c *searchContact) displayName() string {
+	for _, field := range []string{fieldFullName, fieldFirstName, fieldBusinessName, fieldPushName} {
+		if name := c.names[field]; name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// contactMatcher scores how well contacts match a search query
+type contactMatcher struct {
+	query  string
+	folded string
+	words  []string
+	digits string
+	phone  string
+	jid    bool
+	empty  bool
+}
+
+func newContactMatcher(query, defaultCountryCode string) *contactMatcher {
+	m := &contactMatcher{query: strings.TrimSpace(query)}
+	m.folded = foldName(m.query)
+	m.words = strings.Fields(m.folded)
+	m.jid = strings.Contains(m.query, "@")
+	m.empty = m.query == ""
+
+	// Digits only match phone numbers when the query has nothing else in it
+	if strings.Trim(m.query, "0123456789+ -.()/") == "" {
+		m.digits = strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, m.query)
+		if phone, err := normalizePhone(m.query, defaultCountryCode); err == nil {
+			m.phone = strings.TrimPrefix(phone, "+")
+		}
+	}
+	return m
+}
+
+// match returns the field of c that best matches the query and the match quality,
+// between 0 for no match and 1 for an exact one
+func (m *contactMatcher) match(c *searchContact) (string, float64) {
+	switch {
+	case m.jid:
+		if m.query == c.JID || m.query == c.LID {
+			return fieldJID, 1
+		}
+		return "", 0
+	case m.digits != "":
+		return fieldPhoneNumber, m.matchPhone(c.PhoneNumber)
+	}
+
+	var best string
+	var quality float64
+	// The order breaks ties in favour of the names we'd rather show
+	for _, field := range []string{fieldFullName, fieldFirstName, fieldBusinessName, fieldPushName} {
+		if q := m.matchName(c.names[field]); q > quality {
+			best, quality = field, q
+		}
+	}
+	return best, quality
+}
+
+// matchPhone scores a phone number against a query made of digits
+func (m *contactMatcher) matchPhone(phone string) float64 {
+	switch {
+	case phone == "":
+		return 0
+	case phone == m.digits || phone == m.phone:
+		return 1
+	case len(m.digits) < minPhoneQuery:
+		return 0
+	case strings.HasPrefix(phone, m.digits):
+		return 0.9
+	case strings.Contains(phone, m.digits), m.phone != "" && strings.Contains(phone, m.phone):
+		return 0.75
+	}
+	return 0
+}
+
+// matchName scores a name against the query. Matching the whole name or its
+// start beats matching a word or its start, then a match inside a word, then
+// names that only have every query word somewhere, with typos costing the most.
+func (m *contactMatcher) matchName(name string) float64 {
+	folded := foldName(name)
+	if folded == "" || m.folded == "" {
+		return 0
+	}
+
+	switch {
+	case folded == m.folded:
+		return 1
+	case strings.HasPrefix(folded, m.folded):
+		return 0.9
+	case strings.Contains(" "+folded+" ", " "+m.folded+" "):
+		return 0.85
+	case strings.Contains(" "+folded, " "+m.folded):
+		return 0.8
+	case strings.Contains(folded, m.folded):
+		return 0.7
+	}
+
+	// Otherwise every query word must match a word of the name, in any order
+	nameWords := strings.Fields(folded)
+	var total float64
+	for _, word := range m.words {
+		best := 0.0
+		for _, nameWord := range nameWords {
+			best = max(best, matchWord(word, nameWord))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+	return 0.6 * total / float64(len(m.words))
+}
+
+// matchWord scores a query word against a word of a name, allowing for typos
+// and for the query word to be the start of the name word
+func matchWord(word, nameWord string) float64 {
+	switch {
+	case word == nameWord:
+		return 1
+	case strings.HasPrefix(nameWord, word):
+		return 0.9
+	}
+
+	w := []rune(word)
+	allowed := typoBudget(len(w))
+	if allowed == 0 {
+		return 0
+	}
+
+	// Compare against the whole name word and against its start, so a mistyped
+	// beginning of a name still matches
+	n := []rune(nameWord)
+	distance := editDistance(w, n)
+	for _, length := range []int{len(w) - 1, len(w), len(w) + 1} {
+		if length > 0 && length < len(n) {
+			distance = min(distance, editDistance(w, n[:length]))
+		}
+	}
+	if distance > allowed {
+		return 0
+	}
+	return 0.8 * (1 - float64(distance)/float64(len(w)))
+}
+
+// typoBudget is how many typos a query word of length runes may contain
+func typoBudget(length int) int {
+	switch {
+	case length <= 3:
+		return 0
+	case length <= 6:
+		return 1
+	}
+	return 2
+}
+
+// editDistance counts the insertions, deletions, substitutions and swaps of
+// adjacent letters that turn a into b
+func editDistance(a, b []rune) int {
+	// Three rows of the optimal string alignment distance matrix are enough
+	prev2 := make([]int, len(b)+1)
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(b)]
+}
+
+// foldName lowercases a name, strips its accents and turns punctuation into
+// spaces, so "José-María" and "jose maria" compare equal
+func foldName(name string) string {
+	stripped, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil {
+		stripped = name
+	}
+
+	var folded strings.Builder
+	space := true
+	for _, r := range strings.ToLower(stripped) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			folded.WriteRune(r)
+			space = false
+		} else if !space {
+			folded.WriteRune(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(folded.String())
+}
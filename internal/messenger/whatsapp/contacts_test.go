@@ -0,0 +1,168 @@
+package whatsapp
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestFoldName(t *testing.T) {
+	tests := []struct{ name, want string }{
+		{"José-María", "jose maria"},
+		{"  JOÃO   Conceição ", "joao conceicao"},
+		{"Zoë O'Brien", "zoe o brien"},
+		{"Ñandú (work)", "nandu work"},
+		{"Müller & Söhne GmbH", "muller sohne gmbh"},
+		{"李小龍", "李小龍"},
+		{"Agent 007", "agent 007"},
+		{"🙂", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := foldName(tt.name); got != tt.want {
+			t.Errorf("foldName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"maria", "maria", 0},
+		{"maria", "mara", 1},
+		{"maria", "marias", 1},
+		{"maria", "marta", 1},
+		{"maria", "mairа", 2}, // the last letter is Cyrillic
+		{"maria", "mraia", 1},
+		{"ca", "abc", 3}, // optimal string alignment doesn't edit a swapped pair again
+		{"joão", "joao", 1},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance([]rune(tt.a), []rune(tt.b)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance([]rune(tt.b), []rune(tt.a)); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestMatchWord(t *testing.T) {
+	tests := []struct {
+		word, nameWord string
+		want           float64
+	}{
+		{"maria", "maria", 1},
+		{"mar", "maria", 0.9},
+		{"mraia", "maria", 0.8 * (1 - 1.0/5)},
+		{"marai", "mariana", 0.8 * (1 - 1.0/5)},
+		{"alexnader", "alexander", 0.8 * (1 - 1.0/9)},
+		{"alxenadr", "alexander", 0},
+		{"mra", "mar", 0}, // too short for any typo
+		{"jonh", "john", 0.8 * (1 - 1.0/4)},
+		{"jhno", "john", 0}, // two typos in a short word
+		{"smith", "maria", 0},
+	}
+	for _, tt := range tests {
+		if got := matchWord(tt.word, tt.nameWord); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("matchWord(%q, %q) = %v, want %v", tt.word, tt.nameWord, got, tt.want)
+		}
+	}
+}
+
+func TestTypoBudget(t *testing.T) {
+	for length, want := range []int{0, 0, 0, 0, 1, 1, 1, 2, 2, 2} {
+		if got := typoBudget(length); got != want {
+			t.Errorf("typoBudget(%d) = %d, want %d", length, got, want)
+		}
+	}
+}
+
+func TestMatchName(t *testing.T) {
+	tests := []struct {
+		query, name string
+		want        float64
+	}{
+		{"maria silva", "Maria Silva", 1},
+		{"MARIA SILVA", "María Silva", 1},
+		{"joao", "João Conceição", 0.9},
+		{"conceicao", "João Conceição", 0.85},
+		{"conc", "João Conceição", 0.8},
+		{"ceição", "João Conceição", 0.7},
+		{"silva maria", "Maria Silva", 0.6},
+		{"silva mar", "Maria Silva", 0.6 * (1 + 0.9) / 2},
+		{"silav maria", "Maria Silva", 0.6 * (0.8*(1-1.0/5) + 1) / 2},
+		{"maria santos", "Maria Silva", 0},
+		{"maria", "", 0},
+		{"", "Maria Silva", 0},
+		{"🙂", "Maria 🙂", 0},
+	}
+	for _, tt := range tests {
+		m := newContactMatcher(tt.query, "")
+		if got := m.matchName(tt.name); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("matchName(%q) of %q = %v, want %v", tt.query, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContactMatcherRanking(t *testing.T) {
+	contact := func(jid, phone string, names map[string]string) *searchContact {
+		return &searchContact{Contact: Contact{JID: jid, PhoneNumber: phone}, names: names}
+	}
+	contacts := []*searchContact{
+		contact("351910000001@s.whatsapp.net", "351910000001", map[string]string{fieldFullName: "Ana Sousa"}),
+		contact("351910000002@s.whatsapp.net", "351910000002", map[string]string{fieldFullName: "Mariana Costa"}),
+		contact("351910000003@s.whatsapp.net", "351910000003", map[string]string{fieldPushName: "Ana"}),
+		contact("351910000004@s.whatsapp.net", "351910000004", map[string]string{fieldBusinessName: "Anabela's Bakery"}),
+		contact("351910000005@s.whatsapp.net", "351910000005", map[string]string{fieldFullName: "Joana Sá", fieldPushName: "Aña"}),
+		contact("351910000006@s.whatsapp.net", "351910000006", map[string]string{fieldFullName: "Bruno Dias"}),
+	}
+
+	tests := []struct {
+		query     string
+		want      []string
+		wantField string
+	}{
+		// Exact names first, then prefixes, then matches inside a word
+		{"ana", []string{"351910000003", "351910000005", "351910000001", "351910000004", "351910000002"}, fieldPushName},
+		{"sousa ana", []string{"351910000001"}, fieldFullName},
+		{"anabela bakery", []string{"351910000004"}, fieldBusinessName},
+		{"joanna", []string{"351910000005"}, fieldFullName},
+		{"+351 910 000 006", []string{"351910000006"}, fieldPhoneNumber},
+		{"910000", []string{"351910000001", "351910000002", "351910000003", "351910000004", "351910000005", "351910000006"}, fieldPhoneNumber},
+		{"351910000002@s.whatsapp.net", []string{"351910000002"}, fieldJID},
+		{"carlos", nil, ""},
+	}
+	for _, tt := range tests {
+		m := newContactMatcher(tt.query, "351")
+		type result struct {
+			phone, field string
+			quality      float64
+		}
+		var results []result
+		for _, c := range contacts {
+			if field, quality := m.match(c); quality > 0 {
+				results = append(results, result{c.PhoneNumber, field, quality})
+			}
+		}
+		slices.SortStableFunc(results, func(a, b result) int { return cmp.Compare(b.quality, a.quality) })
+
+		var got []string
+		for _, r := range results {
+			got = append(got, r.phone)
+		}
+		if len(results) > 0 && results[0].field != tt.wantField {
+			t.Errorf("query %q matched the best contact by %q, want %q", tt.query, results[0].field, tt.wantField)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("query %q ranked %v, want %v", tt.query, results, tt.want)
+		}
+	}
+}
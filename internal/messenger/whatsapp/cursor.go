@@ -0,0 +1,47 @@
+package whatsapp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// pageCursor marks a position in a listing. It is handed to MCP clients as an
+// opaque string and only ever decoded by the listing that produced it.
+type pageCursor struct {
+	// Timestamp, RowID and JID locate the boundary row of a chronological listing
+	Timestamp int64  `json:"t,omitempty"`
+	RowID     int64  `json:"r,omitempty"`
+	JID       string `json:"j,omitempty"`
+
+	// Offset is used by relevance-ranked search results, which have no stable key
+	Offset int `json:"o,omitempty"`
+
+	// Newer pages towards more recent rows instead of older ones
+	Newer bool `json:"n,omitempty"`
+}
+
+// encode serialises the cursor into an opaque token
+func (c pageCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a token produced by encode. An empty token is the first page.
+func decodeCursor(token string) (*pageCursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var c pageCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &c, nil
+}
@@ -0,0 +1,32 @@
+package whatsapp
+
+import "testing"
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	for _, c := range []pageCursor{
+		{},
+		{Timestamp: 1700000000, RowID: 42},
+		{Timestamp: 1700000000, JID: "15551234567@s.whatsapp.net", Newer: true},
+		{Offset: 40},
+	} {
+		got, err := decodeCursor(c.encode())
+		if err != nil {
+			t.Errorf("decodeCursor(%+v.encode()): %v", c, err)
+			continue
+		}
+		if *got != c {
+			t.Errorf("decodeCursor(%+v.encode()) = %+v", c, *got)
+		}
+	}
+}
+
+func TestDecodeCursor(t *testing.T) {
+	if c, err := decodeCursor(""); c != nil || err != nil {
+		t.Errorf(`decodeCursor("") = %v, %v; want the first page`, c, err)
+	}
+	for _, token := range []string{"not a cursor!", "bm90IGpzb24", "eyJ0IjoieCJ9"} {
+		if _, err := decodeCursor(token); err == nil {
+			t.Errorf("decodeCursor(%q) succeeded, want an error", token)
+		}
+	}
+}
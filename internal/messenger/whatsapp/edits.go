@@ -0,0 +1,238 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// revokeWindow is how long after sending a message can be deleted for everyone. It
+// mirrors the limit WhatsApp's servers currently enforce, 2 days and 12 hours.
+const revokeWindow = 60 * time.Hour
+
+// editMessage replaces the text of one of our own messages, or the caption of an
+// image, video or document. WhatsApp only accepts edits within
+// whatsmeow.EditWindow of the original being sent.
+func (a *account) editMessage(ctx context.Context, chatJID, messageID, text string) (*Message, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, original, err := a.lookupMessage(ctx, chatJID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case text == "":
+		return nil, fmt.Errorf("new text is required")
+	case !original.IsFromMe:
+		return nil, fmt.Errorf("only your own messages can be edited")
+	case original.Revoked:
+		return nil, fmt.Errorf("message %s was deleted", messageID)
+	}
+	if age := time.Since(original.Timestamp); age > whatsmeow.EditWindow {
+		return nil, fmt.Errorf("message %s was sent %s ago, and WhatsApp only allows edits within %s of sending",
+			messageID, age.Round(time.Minute), whatsmeow.EditWindow)
+	}
+
+	content := &waProto.Message{Conversation: proto.String(text)}
+	if original.MediaType != "" {
+		// A caption is edited by sending the whole media message again with the new caption
+		media, err := a.store.messageMedia(ctx, original.ChatJID, messageID)
+		if err != nil {
+			return nil, err
+		}
+		if media == nil {
+			return nil, fmt.Errorf("the attachment of message %s isn't stored, so its caption can't be edited", messageID)
+		}
+		if content, err = captionEdit(media, text); err != nil {
+			return nil, err
+		}
+	}
+
+	edit := a.client.BuildEdit(jid, messageID, content)
+	resp, err := a.client.SendMessage(ctx, jid, edit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	if err := a.store.editMessage(ctx, original.ChatJID, messageID, text, resp.Timestamp); err != nil {
+		log.Error().Err(err).Str("id", messageID).Msg("Failed to store edited message")
+	}
+
+	original.Text = text
+	original.EditedAt = &resp.Timestamp
+	log.Info().Str("chat", original.ChatJID).Str("id", messageID).Msg("Message edited")
+	return original, nil
+}
+
+// captionEdit returns a copy of a stored media message with its caption replaced,
+// or an error for media that can't have a caption
+func captionEdit(media *waProto.Message, caption string) (*waProto.Message, error) {
+	edited := proto.Clone(media).(*waProto.Message)
+	switch {
+	case edited.GetImageMessage() != nil:
+		edited.ImageMessage.Caption = proto.String(caption)
+	case edited.GetVideoMessage() != nil:
+		edited.VideoMessage.Caption = proto.String(caption)
+	case edited.GetDocumentMessage() != nil:
+		edited.DocumentMessage.Caption = proto.String(caption)
+	default:
+		return nil, fmt.Errorf("only text messages and the captions of images, videos and documents can be edited")
+	}
+	return edited, nil
+}
+
+// deleteMessage deletes a message for everyone. Other people's messages can only
+// be deleted in groups where we are an admin.
+func (a *account) deleteMessage(ctx context.Context, chatJID, messageID string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	jid, original, err := a.lookupMessage(ctx, chatJID, messageID)
+	if err != nil {
+		return err
+	}
+	if original.Revoked {
+		return fmt.Errorf("message %s was already deleted", messageID)
+	}
+
+	// An empty sender revokes our own message; a group admin names the sender instead
+	sender := types.EmptyJID
+	if !original.IsFromMe {
+		if jid.Server != types.GroupServer {
+			return fmt.Errorf("only your own messages can be deleted in direct chats")
+		}
+		if sender, err = a.keySender(ctx, original, a.chatUsesLIDs(jid)); err != nil {
+			return err
+		}
+	}
+	if age := time.Since(original.Timestamp); age > revokeWindow {
+		return fmt.Errorf("message %s was sent %s ago, and WhatsApp only allows deleting for everyone within %s of sending",
+			messageID, age.Round(time.Minute), revokeWindow)
+	}
+
+	if _, err := a.client.SendMessage(ctx, jid, a.client.BuildRevoke(jid, sender, messageID)); err != nil {
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	if err := a.store.revokeMessage(ctx, original.ChatJID, messageID); err != nil {
+		log.Error().Err(err).Str("id", messageID).Msg("Failed to store deleted message")
+	}
+
+	log.Info().Str("chat", original.ChatJID).Str("id", messageID).Msg("Message deleted")
+	return nil
+}
+
+// lookupMessage parses a chat JID and loads one of its stored messages
+func (a *account) lookupMessage(ctx context.Context, chatJID, messageID string) (types.JID, *Message, error) {
+	jid, err := a.parseJID(ctx, chatJID)
+	if err != nil {
+		return types.JID{}, nil, err
+	}
+
+	msg, err := a.store.getMessage(ctx, jid.String(), messageID)
+	if err != nil {
+		return types.JID{}, nil, fmt.Errorf("failed to look up message: %w", err)
+	}
+	if msg == nil {
+		return types.JID{}, nil, fmt.Errorf("message %s not found in chat %s", messageID, jid)
+	}
+	return jid, msg, nil
+}
+
+// registerEditTools registers the MCP tools for changing sent messages
+func (w *WhatsAppMessenger) registerEditTools(mcpServer *server.MCPServer) {
+	// edit_message
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "edit_message",
+		Description: "Edit the text of a message you sent, or the caption of an image, video or document you sent. Audio and stickers can't be edited. WhatsApp only allows edits within 20 minutes of sending.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the chat containing the message",
+				},
+				"message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The ID of the message to edit, as returned by send_message",
+				},
+				"text": map[string]interface{}{
+					"type":        "string",
+					"description": "The new message text, or the new caption of a media message",
+				},
+			},
+			Required: []string{"chat_jid", "message_id", "text"},
+		},
+	}, (*account).handleEditMessage)
+
+	// delete_message
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "delete_message",
+		Description: "Delete a message for everyone. Works for your own messages, and for other people's messages in groups where you are an admin, within 60 hours of sending.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the chat containing the message",
+				},
+				"message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The ID of the message to delete",
+				},
+			},
+			Required: []string{"chat_jid", "message_id"},
+		},
+	}, (*account).handleDeleteMessage)
+}
+
+func (a *account) handleEditMessage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChatJID   string `json:"chat_jid"`
+		MessageID string `json:"message_id"`
+		Text      string `json:"text"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	edited, err := a.editMessage(ctx, args.ChatJID, args.MessageID, args.Text)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("edit message failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(edited)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleDeleteMessage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChatJID   string `json:"chat_jid"`
+		MessageID string `json:"message_id"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.deleteMessage(ctx, args.ChatJID, args.MessageID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("delete message failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText("Message deleted for everyone"), nil
+}
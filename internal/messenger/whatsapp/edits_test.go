@@ -0,0 +1,57 @@
+package whatsapp
+
+import (
+	"testing"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCaptionEdit(t *testing.T) {
+	url := proto.String("https://mmg.whatsapp.net/media")
+	tests := []struct {
+		name  string
+		media *waProto.Message
+		want  *waProto.Message // nil if the caption can't be edited
+	}{
+		{
+			name:  "image",
+			media: &waProto.Message{ImageMessage: &waProto.ImageMessage{URL: url, Caption: proto.String("old")}},
+			want:  &waProto.Message{ImageMessage: &waProto.ImageMessage{URL: url, Caption: proto.String("new")}},
+		},
+		{
+			name:  "video without caption",
+			media: &waProto.Message{VideoMessage: &waProto.VideoMessage{URL: url}},
+			want:  &waProto.Message{VideoMessage: &waProto.VideoMessage{URL: url, Caption: proto.String("new")}},
+		},
+		{
+			name:  "document",
+			media: &waProto.Message{DocumentMessage: &waProto.DocumentMessage{URL: url, FileName: proto.String("a.pdf"), Caption: proto.String("old")}},
+			want:  &waProto.Message{DocumentMessage: &waProto.DocumentMessage{URL: url, FileName: proto.String("a.pdf"), Caption: proto.String("new")}},
+		},
+		{name: "audio", media: &waProto.Message{AudioMessage: &waProto.AudioMessage{URL: url}}},
+		{name: "sticker", media: &waProto.Message{StickerMessage: &waProto.StickerMessage{URL: url}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := proto.Clone(tt.media)
+			got, err := captionEdit(tt.media, "new")
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("captionEdit = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("captionEdit: %v", err)
+			}
+			if !proto.Equal(got, tt.want) {
+				t.Errorf("captionEdit = %v, want %v", got, tt.want)
+			}
+			if !proto.Equal(tt.media, stored) {
+				t.Errorf("captionEdit changed the stored message to %v", tt.media)
+			}
+		})
+	}
+}
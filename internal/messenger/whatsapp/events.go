@@ -0,0 +1,310 @@
+package whatsapp
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// handleEvent receives every event emitted by the whatsmeow client
+func (a *account) handleEvent(evt interface{}) {
+	switch evt := evt.(type) {
+	case *events.Message:
+		a.handleMessageEvent(evt)
+	case *events.HistorySync:
+		a.handleHistorySync(evt)
+	case *events.Archive:
+		a.updateChat(evt.JID, "archived", func(ctx context.Context, jid string) error {
+			return a.store.setChatArchived(ctx, jid, evt.Action.GetArchived())
+		})
+	case *events.Pin:
+		a.updateChat(evt.JID, "pinned", func(ctx context.Context, jid string) error {
+			return a.store.setChatPinned(ctx, jid, evt.Action.GetPinned())
+		})
+	case *events.Mute:
+		a.updateChat(evt.JID, "muted", func(ctx context.Context, jid string) error {
+			var until *time.Time
+			// The app state mute end is in milliseconds, with -1 meaning forever
+			if end := evt.Action.GetMuteEndTimestamp(); end > 0 {
+				t := time.UnixMilli(end)
+				until = &t
+			}
+			return a.store.setChatMuted(ctx, jid, evt.Action.GetMuted(), until)
+		})
+	case *events.JoinedGroup:
+		a.updateChat(evt.JID, "name", func(ctx context.Context, jid string) error {
+			return a.store.setChatName(ctx, jid, evt.Name)
+		})
+	case *events.Receipt:
+		a.handleReceipt(evt)
+	case *events.Presence:
+		a.handlePresence(evt)
+	case *events.Connected, *events.Disconnected, *events.KeepAliveTimeout, *events.ConnectFailure,
+		*events.TemporaryBan, *events.LoggedOut, *events.StreamReplaced, *events.ClientOutdated, *events.StreamError:
+		a.handleConnectionEvent(evt)
+	case *events.MarkChatAsRead:
+		a.updateChat(evt.JID, "unread", func(ctx context.Context, jid string) error {
+			if evt.Action.GetRead() {
+				return a.store.markChatRead(ctx, jid, nil)
+			}
+			return a.store.markChatUnread(ctx, jid)
+		})
+	case *events.GroupInfo:
+		if evt.Name != nil {
+			a.updateChat(evt.JID, "name", func(ctx context.Context, jid string) error {
+				return a.store.setChatName(ctx, jid, evt.Name.Name)
+			})
+		}
+		if len(evt.Promote) > 0 || len(evt.Demote) > 0 || len(evt.Leave) > 0 {
+			a.updateChat(evt.JID, "admins", a.store.forgetGroupAdmins)
+		}
+	}
+}
+
+// updateChat applies a chat metadata change reported by another device or the server
+func (a *account) updateChat(chatJID types.JID, field string, update func(ctx context.Context, jid string) error) {
+	ctx := context.Background()
+	jid := a.phoneJID(ctx, chatJID).String()
+	if err := update(ctx, jid); err != nil {
+		log.Error().Err(err).Str("chat", jid).Str("field", field).Msg("Failed to update chat")
+	}
+}
+
+// handleMessageEvent records incoming messages and messages sent from our other devices
+func (a *account) handleMessageEvent(evt *events.Message) {
+	if protocolMsg := evt.Message.GetProtocolMessage(); protocolMsg != nil {
+		a.handleProtocolMessage(evt, protocolMsg)
+		return
+	}
+
+	ctx := context.Background()
+	if reaction, ok := a.reactionFromEvent(ctx, evt); ok {
+		if err := a.store.saveReaction(ctx, reaction); err != nil {
+			log.Error().Err(err).Str("id", reaction.MessageID).Str("chat", reaction.ChatJID).Msg("Failed to store reaction")
+		}
+		return
+	}
+
+	msg, ok := a.messageFromEvent(ctx, evt)
+	if !ok {
+		return
+	}
+
+	if err := a.store.receiveMessage(ctx, msg); err != nil {
+		log.Error().Err(err).Str("id", msg.ID).Str("chat", msg.ChatJID).Msg("Failed to store message")
+		return
+	}
+
+	log.Debug().Str("id", msg.ID).Str("chat", msg.ChatJID).Msg("Message stored")
+}
+
+// handleProtocolMessage applies edits and deletions to a stored message. Only its
+// sender may edit it, and only its sender or a group admin may delete it. Changes
+// to a message that isn't stored yet are kept until it arrives, and deletions by
+// someone else are then checked against the group admins cached by that time.
+func (a *account) handleProtocolMessage(evt *events.Message, protocolMsg *waProto.ProtocolMessage) {
+	msgType := protocolMsg.GetType()
+	if msgType != waProto.ProtocolMessage_MESSAGE_EDIT && msgType != waProto.ProtocolMessage_REVOKE {
+		return
+	}
+
+	ctx := context.Background()
+	chat := a.sourceChat(ctx, evt.Info.MessageSource)
+	sender := a.sourceSender(ctx, evt.Info.MessageSource)
+	change := pendingChange{
+		ChatJID:   chat.String(),
+		MessageID: protocolMsg.GetKey().GetID(),
+		Kind:      changeRevoke,
+		Sender:    sender.String(),
+		IsFromMe:  evt.Info.IsFromMe,
+		Timestamp: evt.Info.Timestamp,
+	}
+	if msgType == waProto.ProtocolMessage_MESSAGE_EDIT {
+		change.Kind = changeEdit
+		change.Text = messageText(protocolMsg.GetEditedMessage())
+	}
+
+	target, err := a.store.getMessage(ctx, change.ChatJID, change.MessageID)
+	if err != nil {
+		log.Error().Err(err).Str("id", change.MessageID).Str("chat", change.ChatJID).Msg("Failed to look up changed message")
+		return
+	}
+	bySender := target != nil && (target.Sender == change.Sender || (target.IsFromMe && change.IsFromMe))
+	if target != nil && !bySender {
+		if change.Kind == changeEdit || chat.Server != types.GroupServer {
+			log.Warn().Str("id", change.MessageID).Str("chat", change.ChatJID).Str("sender", change.Sender).Str("kind", change.Kind).Msg("Ignoring change to someone else's message")
+			return
+		}
+
+		// Checking for admin rights may ask the server, which mustn't hold up event dispatch
+		go func() {
+			checkCtx, cancel := context.WithTimeout(ctx, groupAdminsTimeout)
+			defer cancel()
+			if !a.isGroupAdmin(checkCtx, chat, change.Sender) {
+				log.Warn().Str("id", change.MessageID).Str("chat", change.ChatJID).Str("sender", change.Sender).Msg("Ignoring deletion of someone else's message by a non-admin")
+				return
+			}
+			a.applyChange(ctx, change)
+		}()
+		return
+	}
+	a.applyChange(ctx, change)
+}
+
+// applyChange records an edit or deletion, which applies it if its message is stored
+func (a *account) applyChange(ctx context.Context, change pendingChange) {
+	if err := a.store.recordChange(ctx, change); err != nil {
+		log.Error().Err(err).Str("id", change.MessageID).Str("chat", change.ChatJID).Msg("Failed to apply message change")
+	}
+}
+
+// handleReceipt records delivery, read and played receipts for messages we sent
+func (a *account) handleReceipt(evt *events.Receipt) {
+	// Reading a chat on another of our devices sends us a read-self receipt
+	if evt.Type == types.ReceiptTypeReadSelf {
+		a.updateChat(evt.Chat, "unread", func(ctx context.Context, jid string) error {
+			return a.store.markChatRead(ctx, jid, evt.MessageIDs)
+		})
+		return
+	}
+	// Our other devices also acknowledge messages, which says nothing about the recipients
+	if evt.IsFromMe {
+		return
+	}
+
+	ctx := context.Background()
+	chat := a.sourceChat(ctx, evt.MessageSource).String()
+	recipient := a.sourceSender(ctx, evt.MessageSource).String()
+	for _, id := range evt.MessageIDs {
+		if err := a.store.saveReceipt(ctx, chat, id, recipient, evt.Type, evt.Timestamp); err != nil {
+			log.Error().Err(err).Str("id", id).Str("chat", chat).Msg("Failed to store receipt")
+			return
+		}
+	}
+}
+
+// messageFromEvent converts a whatsmeow message event into a stored Message,
+// with users addressed by LID resolved to their phone number where known.
+// It returns false for events that don't carry content of their own.
+func (a *account) messageFromEvent(ctx context.Context, evt *events.Message) (Message, bool) {
+	// Protocol messages and reactions modify other messages and have no content of their own
+	if evt.Message.GetProtocolMessage() != nil || evt.Message.GetReactionMessage() != nil {
+		return Message{}, false
+	}
+
+	msg := Message{
+		ID:        evt.Info.ID,
+		ChatJID:   a.sourceChat(ctx, evt.Info.MessageSource).String(),
+		Sender:    a.sourceSender(ctx, evt.Info.MessageSource).String(),
+		Text:      messageText(evt.Message),
+		Timestamp: evt.Info.Timestamp,
+		IsFromMe:  evt.Info.IsFromMe,
+
+		senderAddress: evt.Info.Sender.ToNonAD().String(),
+	}
+	msg.MediaType, msg.media = messageMedia(evt.Message)
+	msg.ReplyTo = quotedMessage(evt.Message)
+	if msg.ReplyTo != nil {
+		msg.ReplyTo.Sender = a.canonicalJID(ctx, msg.ReplyTo.Sender)
+	}
+
+	return msg, true
+}
+
+// reactionFromEvent converts a reaction message event into the reaction it sets or removes
+func (a *account) reactionFromEvent(ctx context.Context, evt *events.Message) (messageReaction, bool) {
+	reaction := evt.Message.GetReactionMessage()
+	if reaction == nil {
+		return messageReaction{}, false
+	}
+
+	r := messageReaction{
+		ChatJID:   a.sourceChat(ctx, evt.Info.MessageSource).String(),
+		MessageID: reaction.GetKey().GetID(),
+		Sender:    a.sourceSender(ctx, evt.Info.MessageSource).String(),
+		Emoji:     reaction.GetText(),
+		Timestamp: evt.Info.Timestamp,
+	}
+	if ms := reaction.GetSenderTimestampMS(); ms > 0 {
+		r.Timestamp = time.UnixMilli(ms)
+	}
+	return r, true
+}
+
+// quotedMessage returns the message a reply quotes, or nil if msg isn't a reply
+func quotedMessage(msg *waProto.Message) *QuotedMessage {
+	ctxInfo := messageContextInfo(msg)
+	if ctxInfo.GetStanzaID() == "" {
+		return nil
+	}
+	return &QuotedMessage{
+		ID:     ctxInfo.GetStanzaID(),
+		Sender: ctxInfo.GetParticipant(),
+		Text:   messageText(ctxInfo.GetQuotedMessage()),
+	}
+}
+
+// messageContextInfo returns the context info (reply, mentions, forwarding) of
+// the message types that can quote another message
+func messageContextInfo(msg *waProto.Message) *waProto.ContextInfo {
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage().GetContextInfo()
+	case msg.GetLocationMessage() != nil:
+		return msg.GetLocationMessage().GetContextInfo()
+	case msg.GetContactMessage() != nil:
+		return msg.GetContactMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// messageMedia returns the media type of a message and a copy of the message that
+// only holds its attachment, which is kept so the media can be downloaded later
+func messageMedia(msg *waProto.Message) (string, *waProto.Message) {
+	switch {
+	case msg.GetImageMessage() != nil:
+		return "image", &waProto.Message{ImageMessage: msg.GetImageMessage()}
+	case msg.GetVideoMessage() != nil:
+		return "video", &waProto.Message{VideoMessage: msg.GetVideoMessage()}
+	case msg.GetPtvMessage() != nil:
+		return "video", &waProto.Message{VideoMessage: msg.GetPtvMessage()}
+	case msg.GetAudioMessage() != nil:
+		return "audio", &waProto.Message{AudioMessage: msg.GetAudioMessage()}
+	case msg.GetDocumentMessage() != nil:
+		return "document", &waProto.Message{DocumentMessage: msg.GetDocumentMessage()}
+	case msg.GetStickerMessage() != nil:
+		return "sticker", &waProto.Message{StickerMessage: msg.GetStickerMessage()}
+	}
+	return "", nil
+}
+
+// messageText extracts the human-readable text of a message, including media captions
+func messageText(msg *waProto.Message) string {
+	switch {
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	}
+	return ""
+}
@@ -0,0 +1,804 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// getGroupInfo fetches the metadata and participant list of a group
+func (a *account) getGroupInfo(ctx context.Context, groupJID string) (*GroupInfo, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := a.client.GetGroupInfo(jid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get group info: %w", err)
+	}
+	if err := a.cacheGroupAdmins(ctx, info); err != nil {
+		log.Warn().Err(err).Str("group", jid.String()).Msg("Failed to cache group admins")
+	}
+
+	group := a.convertGroupInfo(ctx, info, true)
+	return &group, nil
+}
+
+// listGroups lists the groups we are a member of, without their participant lists
+func (a *account) listGroups(ctx context.Context) ([]GroupInfo, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	infos, err := a.client.GetJoinedGroups(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get joined groups: %w", err)
+	}
+
+	groups := make([]GroupInfo, 0, len(infos))
+	for _, info := range infos {
+		groups = append(groups, a.convertGroupInfo(ctx, info, false))
+
+		// Keep chat names current while we have fresh group metadata
+		if err := a.store.setChatName(ctx, info.JID.String(), info.Name); err != nil {
+			log.Warn().Err(err).Str("group", info.JID.String()).Msg("Failed to update group chat name")
+		}
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+
+	return groups, nil
+}
+
+// convertGroupInfo converts whatsmeow group info, optionally including participants
+func (a *account) convertGroupInfo(ctx context.Context, info *types.GroupInfo, withParticipants bool) GroupInfo {
+	group := GroupInfo{
+		JID:                  info.JID.String(),
+		Name:                 info.Name,
+		Topic:                info.Topic,
+		Created:              info.GroupCreated,
+		Announce:             info.IsAnnounce,
+		Locked:               info.IsLocked,
+		JoinApprovalRequired: info.IsJoinApprovalRequired,
+		ParticipantCount:     len(info.Participants),
+	}
+	if info.IsEphemeral {
+		group.DisappearingTimer = info.DisappearingTimer
+	}
+	if !info.OwnerPN.IsEmpty() {
+		group.Owner = info.OwnerPN.String()
+	} else if !info.OwnerJID.IsEmpty() {
+		group.Owner = a.phoneJID(ctx, info.OwnerJID).String()
+	}
+
+	if withParticipants {
+		for _, p := range info.Participants {
+			group.Participants = append(group.Participants, a.convertGroupParticipant(ctx, p))
+		}
+	}
+
+	return group
+}
+
+// convertGroupParticipant converts a whatsmeow participant and looks up its contact
+// name. Participants of groups that address them by LID are listed under their
+// phone number when it is known, with the LID alongside.
+func (a *account) convertGroupParticipant(ctx context.Context, p types.GroupParticipant) GroupParticipant {
+	jid := a.participantJID(ctx, p)
+	participant := GroupParticipant{
+		JID:          jid.String(),
+		IsAdmin:      p.IsAdmin,
+		IsSuperAdmin: p.IsSuperAdmin,
+		Name:         p.DisplayName,
+	}
+	if jid.Server == types.DefaultUserServer {
+		participant.PhoneNumber = jid.User
+	}
+	if lid := p.LID; !lid.IsEmpty() {
+		participant.LID = lid.ToNonAD().String()
+	} else if lid := a.userLID(ctx, jid); !lid.IsEmpty() {
+		participant.LID = lid.String()
+	}
+
+	if name := a.contactName(ctx, jid); name != "" {
+		participant.Name = name
+	}
+
+	return participant
+}
+
+// participantJID returns the phone number JID of a group participant, or its
+// LID if the number is hidden from us
+func (a *account) participantJID(ctx context.Context, p types.GroupParticipant) types.JID {
+	if !p.PhoneNumber.IsEmpty() {
+		return p.PhoneNumber.ToNonAD()
+	}
+	return a.phoneJID(ctx, p.JID)
+}
+
+// groupAdminsMaxAge is how long the cached admins of a group are trusted for.
+// Admin changes the server tells us about drop the cache sooner.
+const groupAdminsMaxAge = time.Hour
+
+// groupAdminsTimeout bounds how long checking for admin rights waits on the server
+const groupAdminsTimeout = 15 * time.Second
+
+// adminFetch is a request for the admins of a group that concurrent checks share
+type adminFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// isGroupAdmin reports whether user, by LID or phone number, is an admin of
+// group. Admins are cached in the message store, which also checks pending
+// deletions against them, and are fetched when the cache is missing or stale.
+func (a *account) isGroupAdmin(ctx context.Context, group types.JID, user string) bool {
+	synced, err := a.store.groupAdminsSynced(ctx, group.String())
+	if err == nil && time.Since(synced) > groupAdminsMaxAge {
+		err = a.refreshGroupAdmins(ctx, group)
+	}
+	var admin bool
+	if err == nil {
+		admin, err = a.store.isGroupAdmin(ctx, group.String(), user)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("group", group.String()).Msg("Failed to get group admins")
+		return false
+	}
+	return admin
+}
+
+// refreshGroupAdmins fetches the admins of a group into the message store.
+// Concurrent refreshes of a group share one request, and stop waiting for it
+// when ctx is done.
+func (a *account) refreshGroupAdmins(ctx context.Context, group types.JID) error {
+	a.adminFetchMu.Lock()
+	fetch, ok := a.adminFetches[group]
+	if !ok {
+		fetch = &adminFetch{done: make(chan struct{})}
+		a.adminFetches[group] = fetch
+		go a.fetchGroupAdmins(group, fetch)
+	}
+	a.adminFetchMu.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fetchGroupAdmins asks the server for the participants of a group and caches
+// its admins, reporting the outcome through fetch
+func (a *account) fetchGroupAdmins(group types.JID, fetch *adminFetch) {
+	defer func() {
+		a.adminFetchMu.Lock()
+		delete(a.adminFetches, group)
+		a.adminFetchMu.Unlock()
+		close(fetch.done)
+	}()
+
+	info, err := a.client.GetGroupInfo(group)
+	if err != nil {
+		fetch.err = fmt.Errorf("failed to get group info: %w", err)
+		return
+	}
+	fetch.err = a.cacheGroupAdmins(context.Background(), info)
+}
+
+// cacheGroupAdmins stores the admins of a group from its info
+func (a *account) cacheGroupAdmins(ctx context.Context, info *types.GroupInfo) error {
+	var admins []string
+	for _, p := range info.Participants {
+		if p.IsAdmin || p.IsSuperAdmin {
+			admins = append(admins, a.participantJID(ctx, p).String(), p.JID.ToNonAD().String())
+		}
+	}
+	return a.store.setGroupAdmins(ctx, info.JID.String(), admins, time.Now())
+}
+
+// participantErrors describes the error codes WhatsApp reports for individual participants
+var participantErrors = map[int]string{
+	400: "bad request",
+	401: "not authorized",
+	403: "the user's privacy settings don't allow adding them",
+	404: "not a WhatsApp user or not a participant of the group",
+	406: "not acceptable",
+	408: "the user recently left the group",
+	409: "already a participant of the group",
+	500: "the group is full",
+}
+
+// createGroup creates a group with the given subject and initial participants
+func (a *account) createGroup(ctx context.Context, name string, participants []string) (*CreatedGroup, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("group name is required")
+	}
+	jids, err := a.parseParticipants(ctx, participants)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := a.client.CreateGroup(ctx, whatsmeow.ReqCreateGroup{
+		Name:         name,
+		Participants: jids,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create group: %w", err)
+	}
+
+	if err := a.store.setChatName(ctx, info.JID.String(), info.Name); err != nil {
+		log.Warn().Err(err).Str("group", info.JID.String()).Msg("Failed to store group chat name")
+	}
+
+	// The server adds us implicitly; only report on the participants that were asked for
+	own := a.client.Store.GetJID().ToNonAD()
+	ownLID := a.client.Store.GetLID().ToNonAD()
+	created := &CreatedGroup{
+		Group:        a.convertGroupInfo(ctx, info, false),
+		Participants: []GroupParticipantResult{},
+	}
+	for _, p := range info.Participants {
+		if p.JID == own || p.JID == ownLID || p.PhoneNumber == own {
+			continue
+		}
+		created.Participants = append(created.Participants, a.participantResult(ctx, p))
+	}
+
+	log.Info().Str("group", info.JID.String()).Msg("Group created")
+	return created, nil
+}
+
+// updateGroupParticipants adds, removes, promotes or demotes group participants
+func (a *account) updateGroupParticipants(ctx context.Context, groupJID string, participants []string, action string) ([]GroupParticipantResult, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return nil, err
+	}
+
+	change := whatsmeow.ParticipantChange(action)
+	switch change {
+	case whatsmeow.ParticipantChangeAdd, whatsmeow.ParticipantChangeRemove,
+		whatsmeow.ParticipantChangePromote, whatsmeow.ParticipantChangeDemote:
+	default:
+		return nil, fmt.Errorf("invalid action %q, expected add, remove, promote or demote", action)
+	}
+
+	jids, err := a.parseParticipants(ctx, participants)
+	if err != nil {
+		return nil, err
+	}
+	if len(jids) == 0 {
+		return nil, fmt.Errorf("at least one participant is required")
+	}
+
+	updated, err := a.client.UpdateGroupParticipants(jid, jids, change)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s participants: %w", action, err)
+	}
+
+	results := make([]GroupParticipantResult, 0, len(updated))
+	for _, p := range updated {
+		results = append(results, a.participantResult(ctx, p))
+	}
+
+	log.Info().Str("group", jid.String()).Str("action", action).Int("participants", len(jids)).Msg("Group participants updated")
+	return results, nil
+}
+
+// setGroupSubject changes the subject (name) of a group
+func (a *account) setGroupSubject(ctx context.Context, groupJID, subject string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return err
+	}
+	if subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+
+	if err := a.client.SetGroupName(jid, subject); err != nil {
+		return fmt.Errorf("failed to set group subject: %w", err)
+	}
+
+	if err := a.store.setChatName(ctx, jid.String(), subject); err != nil {
+		log.Warn().Err(err).Str("group", jid.String()).Msg("Failed to store group chat name")
+	}
+	return nil
+}
+
+// setGroupDescription changes the description (topic) of a group. An empty description removes it.
+func (a *account) setGroupDescription(ctx context.Context, groupJID, description string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return err
+	}
+
+	// Leaving the topic IDs empty makes whatsmeow look up the current topic ID itself
+	if err := a.client.SetGroupTopic(jid, "", "", description); err != nil {
+		return fmt.Errorf("failed to set group description: %w", err)
+	}
+	return nil
+}
+
+// setGroupPicture sets a group's picture from a JPEG file in the upload directory.
+// An empty path removes the picture.
+func (a *account) setGroupPicture(ctx context.Context, groupJID, imagePath string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return err
+	}
+
+	var avatar []byte
+	if imagePath != "" {
+		path, err := uploadPath(a.config.UploadDir, imagePath)
+		if err != nil {
+			return err
+		}
+		avatar, err = os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read image: %w", err)
+		}
+		if mimeType := http.DetectContentType(avatar); mimeType != "image/jpeg" {
+			return fmt.Errorf("group pictures must be JPEG images, got %s", mimeType)
+		}
+	}
+
+	if _, err := a.client.SetGroupPhoto(jid, avatar); err != nil {
+		return fmt.Errorf("failed to set group picture: %w", err)
+	}
+	return nil
+}
+
+// setGroupSettings toggles announce mode (only admins send) and locked mode (only admins edit info)
+func (a *account) setGroupSettings(ctx context.Context, groupJID string, announce, locked *bool) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return err
+	}
+	if announce == nil && locked == nil {
+		return fmt.Errorf("nothing to change, set announce and/or locked")
+	}
+
+	if announce != nil {
+		if err := a.client.SetGroupAnnounce(jid, *announce); err != nil {
+			return fmt.Errorf("failed to set announce mode: %w", err)
+		}
+	}
+	if locked != nil {
+		if err := a.client.SetGroupLocked(jid, *locked); err != nil {
+			return fmt.Errorf("failed to set locked mode: %w", err)
+		}
+	}
+	return nil
+}
+
+// leaveGroup leaves a group
+func (a *account) leaveGroup(ctx context.Context, groupJID string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.client.LeaveGroup(jid); err != nil {
+		return fmt.Errorf("failed to leave group: %w", err)
+	}
+
+	log.Info().Str("group", jid.String()).Msg("Left group")
+	return nil
+}
+
+// participantResult converts a participant returned by a group change into its outcome
+func (a *account) participantResult(ctx context.Context, p types.GroupParticipant) GroupParticipantResult {
+	result := GroupParticipantResult{
+		JID:     a.participantJID(ctx, p).String(),
+		Success: p.Error == 0,
+	}
+	if p.Error != 0 {
+		result.ErrorCode = p.Error
+		result.Error = participantErrors[p.Error]
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("error %d", p.Error)
+		}
+		result.InviteRequired = p.AddRequest != nil
+	}
+	return result
+}
+
+// parseParticipants parses a list of participants given as JIDs or phone numbers.
+// JIDs are passed on as given, as groups that address members by LID expect
+// their LIDs, and whatsmeow adds the phone numbers itself.
+func (a *account) parseParticipants(ctx context.Context, participants []string) ([]types.JID, error) {
+	jids := make([]types.JID, 0, len(participants))
+	for _, participant := range participants {
+		var jid types.JID
+		var err error
+		if strings.Contains(participant, "@") {
+			if jid, err = types.ParseJID(participant); err != nil {
+				err = fmt.Errorf("invalid JID: %w", err)
+			}
+		} else {
+			jid, err = a.parseRecipient(ctx, participant)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid participant %q: %w", participant, err)
+		}
+		jids = append(jids, jid)
+	}
+	return jids, nil
+}
+
+// parseGroupJID parses a JID and checks that it refers to a group
+func parseGroupJID(groupJID string) (types.JID, error) {
+	jid, err := types.ParseJID(groupJID)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("invalid JID: %w", err)
+	}
+	if jid.Server != types.GroupServer {
+		return types.JID{}, fmt.Errorf("%s is not a group JID", groupJID)
+	}
+	return jid, nil
+}
+
+// registerGroupTools registers the group MCP tools
+func (w *WhatsAppMessenger) registerGroupTools(mcpServer *server.MCPServer) {
+	// list_groups
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "list_groups",
+		Description: "List the WhatsApp groups you are a member of, with their settings and participant counts",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, (*account).handleListGroups)
+
+	// get_group_info
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "get_group_info",
+		Description: "Get a group's subject, description, creation time, owner, announce/locked settings and participants with their admin status",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the group (ending in @g.us)",
+				},
+			},
+			Required: []string{"group_jid"},
+		},
+	}, (*account).handleGetGroupInfo)
+
+	groupJIDProperty := map[string]interface{}{
+		"type":        "string",
+		"description": "The JID of the group (ending in @g.us)",
+	}
+	participantsProperty := map[string]interface{}{
+		"type":        "array",
+		"items":       map[string]interface{}{"type": "string"},
+		"description": "Participants as JIDs or phone numbers with country code",
+	}
+
+	// create_group
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "create_group",
+		Description: "Create a WhatsApp group. Returns the new group and whether each participant could be added.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The group subject (max 25 characters)",
+				},
+				"participants": participantsProperty,
+			},
+			Required: []string{"name", "participants"},
+		},
+	}, (*account).handleCreateGroup)
+
+	// update_group_participants
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "update_group_participants",
+		Description: "Add, remove, promote to admin or demote group participants. Returns the outcome for each participant, since some may fail while others succeed.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid":    groupJIDProperty,
+				"participants": participantsProperty,
+				"action": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"add", "remove", "promote", "demote"},
+					"description": "The change to apply to the participants",
+				},
+			},
+			Required: []string{"group_jid", "participants", "action"},
+		},
+	}, (*account).handleUpdateGroupParticipants)
+
+	// set_group_subject
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "set_group_subject",
+		Description: "Change the subject (name) of a group",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+				"subject": map[string]interface{}{
+					"type":        "string",
+					"description": "The new subject (max 25 characters)",
+				},
+			},
+			Required: []string{"group_jid", "subject"},
+		},
+	}, (*account).handleSetGroupSubject)
+
+	// set_group_description
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "set_group_description",
+		Description: "Change the description of a group. An empty description removes it.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+				"description": map[string]interface{}{
+					"type":        "string",
+					"description": "The new description",
+				},
+			},
+			Required: []string{"group_jid", "description"},
+		},
+	}, (*account).handleSetGroupDescription)
+
+	// set_group_picture
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "set_group_picture",
+		Description: "Set the picture of a group from a JPEG file, or remove it when no file is given",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+				"image_path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of a JPEG image inside the upload directory (--upload-dir), relative to it unless absolute; omit to remove the picture",
+				},
+			},
+			Required: []string{"group_jid"},
+		},
+	}, (*account).handleSetGroupPicture)
+
+	// set_group_settings
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "set_group_settings",
+		Description: "Toggle announce mode (only admins can send messages) and locked mode (only admins can edit group info)",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+				"announce": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only admins can send messages",
+				},
+				"locked": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only admins can edit the group subject, description and picture",
+				},
+			},
+			Required: []string{"group_jid"},
+		},
+	}, (*account).handleSetGroupSettings)
+
+	// leave_group
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "leave_group",
+		Description: "Leave a WhatsApp group",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+			},
+			Required: []string{"group_jid"},
+		},
+	}, (*account).handleLeaveGroup)
+}
+
+func (a *account) handleListGroups(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	groups, err := a.listGroups(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("list groups failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(groups)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleGetGroupInfo(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID string `json:"group_jid"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	group, err := a.getGroupInfo(ctx, args.GroupJID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("get group info failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(group)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleCreateGroup(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Name         string   `json:"name"`
+		Participants []string `json:"participants"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	created, err := a.createGroup(ctx, args.Name, args.Participants)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("create group failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(created)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleUpdateGroupParticipants(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID     string   `json:"group_jid"`
+		Participants []string `json:"participants"`
+		Action       string   `json:"action"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	results, err := a.updateGroupParticipants(ctx, args.GroupJID, args.Participants, args.Action)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("update group participants failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(results)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleSetGroupSubject(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID string `json:"group_jid"`
+		Subject  string `json:"subject"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.setGroupSubject(ctx, args.GroupJID, args.Subject)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("set group subject failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText("Group subject updated"), nil
+}
+
+func (a *account) handleSetGroupDescription(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID    string `json:"group_jid"`
+		Description string `json:"description"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.setGroupDescription(ctx, args.GroupJID, args.Description)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("set group description failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText("Group description updated"), nil
+}
+
+func (a *account) handleSetGroupPicture(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID  string `json:"group_jid"`
+		ImagePath string `json:"image_path"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.setGroupPicture(ctx, args.GroupJID, args.ImagePath)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("set group picture failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText("Group picture updated"), nil
+}
+
+func (a *account) handleSetGroupSettings(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID string `json:"group_jid"`
+		Announce *bool  `json:"announce"`
+		Locked   *bool  `json:"locked"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.setGroupSettings(ctx, args.GroupJID, args.Announce, args.Locked)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("set group settings failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText("Group settings updated"), nil
+}
+
+func (a *account) handleLeaveGroup(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID string `json:"group_jid"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.leaveGroup(ctx, args.GroupJID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("leave group failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText("Left group successfully"), nil
+}
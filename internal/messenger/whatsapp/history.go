@@ -0,0 +1,137 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// handleHistorySync stores the conversations delivered in a history sync chunk.
+// whatsmeow sends these after pairing and keeps pushing them while the phone
+// uploads older history, so each chunk is recorded as it arrives.
+func (a *account) handleHistorySync(evt *events.HistorySync) {
+	ctx := context.Background()
+	data := evt.Data
+
+	chunk := HistorySyncStatus{
+		SyncType:  data.GetSyncType().String(),
+		LastChunk: int(data.GetChunkOrder()),
+		Progress:  int(data.GetProgress()),
+		// whatsmeow itself writes push names into its contact store
+		PushNames: len(data.GetPushnames()),
+		UpdatedAt: time.Now(),
+	}
+
+	for _, conv := range data.GetConversations() {
+		chatJID, err := types.ParseJID(conv.GetID())
+		if err != nil {
+			log.Warn().Err(err).Str("id", conv.GetID()).Msg("Skipping history sync conversation with invalid JID")
+			continue
+		}
+
+		chat := Chat{
+			JID:          a.phoneJID(ctx, chatJID).String(),
+			Name:         conv.GetName(),
+			IsGroup:      chatJID.Server == types.GroupServer,
+			UnreadCount:  int(conv.GetUnreadCount()),
+			Archived:     conv.GetArchived(),
+			Pinned:       conv.GetPinned() > 0,
+			LastActivity: time.Unix(int64(conv.GetConversationTimestamp()), 0),
+		}
+		if chat.Name == "" {
+			chat.Name = conv.GetDisplayName()
+		}
+		chat.Muted, chat.MutedUntil = historyMute(conv.GetMuteEndTime(), time.Now())
+
+		var messages []Message
+		var reactions []messageReaction
+		for _, historyMsg := range conv.GetMessages() {
+			evt, err := a.client.ParseWebMessage(chatJID, historyMsg.GetMessage())
+			if err != nil {
+				log.Debug().Err(err).Str("chat", chat.JID).Msg("Skipping unparseable history sync message")
+				continue
+			}
+			if msg, ok := a.messageFromEvent(ctx, evt); ok {
+				messages = append(messages, msg)
+			} else if reaction, ok := a.reactionFromEvent(ctx, evt); ok {
+				reactions = append(reactions, reaction)
+			}
+			reactions = append(reactions, a.historyReactions(ctx, chatJID, historyMsg.GetMessage())...)
+		}
+
+		if err := a.store.saveConversation(ctx, chat, messages, reactions); err != nil {
+			log.Error().Err(err).Str("chat", chat.JID).Msg("Failed to store history sync conversation")
+			continue
+		}
+
+		chunk.Conversations++
+		chunk.Messages += len(messages)
+	}
+
+	if err := a.store.recordHistorySync(ctx, chunk); err != nil {
+		log.Error().Err(err).Msg("Failed to record history sync progress")
+	}
+
+	log.Info().
+		Str("type", chunk.SyncType).
+		Int("chunk", chunk.LastChunk).
+		Int("progress", chunk.Progress).
+		Int("conversations", chunk.Conversations).
+		Int("messages", chunk.Messages).
+		Msg("History sync chunk stored")
+}
+
+// historyMute converts the mute end time of a history sync conversation into
+// the chat's mute state at now. Chats muted forever have an end time of -1,
+// which arrives as the largest uint64.
+func historyMute(muteEnd uint64, now time.Time) (bool, *time.Time) {
+	if int64(muteEnd) < 0 {
+		return true, nil
+	}
+	if end := time.Unix(int64(muteEnd), 0); end.After(now) {
+		return true, &end
+	}
+	return false, nil
+}
+
+// historyReactions returns the reactions history sync attaches to a message
+func (a *account) historyReactions(ctx context.Context, chatJID types.JID, webMsg *waProto.WebMessageInfo) []messageReaction {
+	var reactions []messageReaction
+	for _, r := range webMsg.GetReactions() {
+		// The key identifies the reaction itself, and so who sent it
+		sender := chatJID
+		switch {
+		case r.GetKey().GetFromMe():
+			sender = a.client.Store.GetJID()
+		case r.GetKey().GetParticipant() != "":
+			participant, err := types.ParseJID(r.GetKey().GetParticipant())
+			if err != nil {
+				continue
+			}
+			sender = participant
+		}
+
+		reactions = append(reactions, messageReaction{
+			ChatJID:   a.phoneJID(ctx, chatJID).String(),
+			MessageID: webMsg.GetKey().GetID(),
+			Sender:    a.phoneJID(ctx, sender).String(),
+			Emoji:     r.GetText(),
+			Timestamp: time.UnixMilli(r.GetSenderTimestampMS()),
+		})
+	}
+	return reactions
+}
+
+// getHistorySyncStatus reports how much history has been received so far
+func (a *account) getHistorySyncStatus(ctx context.Context) ([]HistorySyncStatus, error) {
+	statuses, err := a.store.historySyncStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get history sync status: %w", err)
+	}
+	return statuses, nil
+}
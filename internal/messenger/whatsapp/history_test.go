@@ -0,0 +1,34 @@
+package whatsapp
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistoryMute(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	later := now.Add(time.Hour)
+	tests := []struct {
+		name      string
+		muteEnd   uint64
+		wantMuted bool
+		wantUntil *time.Time
+	}{
+		{"not muted", 0, false, nil},
+		{"mute expired", uint64(now.Unix() - 60), false, nil},
+		{"muted for a while", uint64(later.Unix()), true, &later},
+		{"muted forever", math.MaxUint64, true, nil},
+	}
+	for _, tt := range tests {
+		muted, until := historyMute(tt.muteEnd, now)
+		if muted != tt.wantMuted || (until == nil) != (tt.wantUntil == nil) || (until != nil && !until.Equal(*tt.wantUntil)) {
+			t.Errorf("%s: historyMute(%d) = %v, %v; want %v, %v", tt.name, tt.muteEnd, muted, until, tt.wantMuted, tt.wantUntil)
+		}
+	}
+
+	muted, until := historyMute(math.MaxUint64, now)
+	if got := mutedUntilValue(Chat{Muted: muted, MutedUntil: until}); got != mutedForever {
+		t.Errorf("a chat muted forever is stored as %d, want %d", got, mutedForever)
+	}
+}
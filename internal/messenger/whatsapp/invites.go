@@ -0,0 +1,358 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+)
+
+// getGroupInviteLink returns a group's invite link, optionally revoking the old one first
+func (a *account) getGroupInviteLink(ctx context.Context, groupJID string, reset bool) (*GroupInviteLink, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return nil, err
+	}
+
+	link, err := a.client.GetGroupInviteLink(jid, reset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invite link: %w", err)
+	}
+
+	if reset {
+		log.Info().Str("group", jid.String()).Msg("Group invite link reset")
+	}
+	return &GroupInviteLink{GroupJID: jid.String(), InviteLink: link}, nil
+}
+
+// previewGroupInvite looks up the group behind an invite link without joining it
+func (a *account) previewGroupInvite(ctx context.Context, inviteLink string) (*GroupInfo, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	code, err := inviteCode(inviteLink)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := a.client.GetGroupInfoFromLink(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve invite link: %w", err)
+	}
+
+	group := a.convertGroupInfo(ctx, info, true)
+	return &group, nil
+}
+
+// joinGroupWithLink joins a group by invite link. Groups that require approval
+// only get a join request, which is reported as pending.
+func (a *account) joinGroupWithLink(ctx context.Context, inviteLink string) (*JoinedGroup, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	code, err := inviteCode(inviteLink)
+	if err != nil {
+		return nil, err
+	}
+
+	// whatsmeow returns the group JID either way, so check up front whether approval is needed
+	info, err := a.client.GetGroupInfoFromLink(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve invite link: %w", err)
+	}
+
+	jid, err := a.client.JoinGroupWithLink(code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to join group: %w", err)
+	}
+
+	joined := &JoinedGroup{GroupJID: jid.String(), PendingApproval: info.IsJoinApprovalRequired}
+	if !joined.PendingApproval {
+		if err := a.store.setChatName(ctx, jid.String(), info.Name); err != nil {
+			log.Warn().Err(err).Str("group", jid.String()).Msg("Failed to store group chat name")
+		}
+	}
+
+	log.Info().Str("group", jid.String()).Bool("pending_approval", joined.PendingApproval).Msg("Joined group with link")
+	return joined, nil
+}
+
+// listGroupJoinRequests lists the pending requests to join a group
+func (a *account) listGroupJoinRequests(ctx context.Context, groupJID string) ([]GroupJoinRequest, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := a.client.GetGroupRequestParticipants(jid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get join requests: %w", err)
+	}
+
+	requests := make([]GroupJoinRequest, 0, len(pending))
+	for _, p := range pending {
+		jid := a.phoneJID(ctx, p.JID)
+		request := GroupJoinRequest{JID: jid.String(), RequestedAt: p.RequestedAt}
+		if lid := a.userLID(ctx, p.JID); !lid.IsEmpty() {
+			request.LID = lid.String()
+		}
+		request.Name = a.contactName(ctx, jid)
+		requests = append(requests, request)
+	}
+
+	return requests, nil
+}
+
+// updateGroupJoinRequests approves or rejects pending requests to join a group
+func (a *account) updateGroupJoinRequests(ctx context.Context, groupJID string, participants []string, action string) ([]GroupParticipantResult, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := parseGroupJID(groupJID)
+	if err != nil {
+		return nil, err
+	}
+
+	change := whatsmeow.ParticipantRequestChange(action)
+	if change != whatsmeow.ParticipantChangeApprove && change != whatsmeow.ParticipantChangeReject {
+		return nil, fmt.Errorf("invalid action %q, expected approve or reject", action)
+	}
+
+	jids, err := a.parseParticipants(ctx, participants)
+	if err != nil {
+		return nil, err
+	}
+	if len(jids) == 0 {
+		return nil, fmt.Errorf("at least one participant is required")
+	}
+
+	updated, err := a.client.UpdateGroupRequestParticipants(jid, jids, change)
+	if err != nil {
+		return nil, fmt.Errorf("failed to %s join requests: %w", action, err)
+	}
+
+	results := make([]GroupParticipantResult, 0, len(updated))
+	for _, p := range updated {
+		results = append(results, a.participantResult(ctx, p))
+	}
+
+	log.Info().Str("group", jid.String()).Str("action", action).Int("participants", len(jids)).Msg("Group join requests updated")
+	return results, nil
+}
+
+// inviteCode extracts the code from an invite link such as https://chat.whatsapp.com/AbC123.
+// A bare code is accepted as well.
+func inviteCode(inviteLink string) (string, error) {
+	code := strings.TrimSpace(inviteLink)
+	if i := strings.IndexAny(code, "?#"); i >= 0 {
+		code = code[:i]
+	}
+	code = strings.TrimRight(code, "/")
+	if i := strings.LastIndexByte(code, '/'); i >= 0 {
+		code = code[i+1:]
+	}
+	if code == "" {
+		return "", fmt.Errorf("invalid invite link %q", inviteLink)
+	}
+	return code, nil
+}
+
+// registerInviteTools registers the group invite MCP tools
+func (w *WhatsAppMessenger) registerInviteTools(mcpServer *server.MCPServer) {
+	groupJIDProperty := map[string]interface{}{
+		"type":        "string",
+		"description": "The JID of the group (ending in @g.us)",
+	}
+	inviteLinkProperty := map[string]interface{}{
+		"type":        "string",
+		"description": "Invite link (https://chat.whatsapp.com/...) or just its code",
+	}
+
+	// get_group_invite_link
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "get_group_invite_link",
+		Description: "Get a group's invite link. With reset, the current link is revoked and a new one is generated. Requires admin rights.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+				"reset": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Revoke the current link and generate a new one (default: false)",
+				},
+			},
+			Required: []string{"group_jid"},
+		},
+	}, (*account).handleGetGroupInviteLink)
+
+	// preview_group_invite
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "preview_group_invite",
+		Description: "Show the group behind an invite link (subject, description, settings, participants) without joining it",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"invite_link": inviteLinkProperty,
+			},
+			Required: []string{"invite_link"},
+		},
+	}, (*account).handlePreviewGroupInvite)
+
+	// join_group_with_link
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "join_group_with_link",
+		Description: "Join a group using an invite link. For groups that require admin approval this sends a join request and reports it as pending.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"invite_link": inviteLinkProperty,
+			},
+			Required: []string{"invite_link"},
+		},
+	}, (*account).handleJoinGroupWithLink)
+
+	// list_group_join_requests
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "list_group_join_requests",
+		Description: "List pending requests to join a group. Requires admin rights.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+			},
+			Required: []string{"group_jid"},
+		},
+	}, (*account).handleListGroupJoinRequests)
+
+	// update_group_join_requests
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "update_group_join_requests",
+		Description: "Approve or reject pending requests to join a group. Returns the outcome for each participant.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"group_jid": groupJIDProperty,
+				"participants": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "JIDs of the requesters, as returned by list_group_join_requests. Use their lid where one is listed.",
+				},
+				"action": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"approve", "reject"},
+					"description": "Whether to approve or reject the requests",
+				},
+			},
+			Required: []string{"group_jid", "participants", "action"},
+		},
+	}, (*account).handleUpdateGroupJoinRequests)
+}
+
+func (a *account) handleGetGroupInviteLink(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID string `json:"group_jid"`
+		Reset    bool   `json:"reset"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	link, err := a.getGroupInviteLink(ctx, args.GroupJID, args.Reset)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("get group invite link failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(link)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handlePreviewGroupInvite(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		InviteLink string `json:"invite_link"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	group, err := a.previewGroupInvite(ctx, args.InviteLink)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("preview group invite failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(group)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleJoinGroupWithLink(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		InviteLink string `json:"invite_link"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	joined, err := a.joinGroupWithLink(ctx, args.InviteLink)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("join group failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(joined)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleListGroupJoinRequests(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID string `json:"group_jid"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	requests, err := a.listGroupJoinRequests(ctx, args.GroupJID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("list group join requests failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(requests)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleUpdateGroupJoinRequests(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		GroupJID     string   `json:"group_jid"`
+		Participants []string `json:"participants"`
+		Action       string   `json:"action"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	results, err := a.updateGroupJoinRequests(ctx, args.GroupJID, args.Participants, args.Action)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("update group join requests failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(results)
+	return mcp.NewToolResultText(string(result)), nil
+}
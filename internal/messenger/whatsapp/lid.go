@@ -0,0 +1,204 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// WhatsApp addresses users either by phone number (@s.whatsapp.net) or by LID
+// (@lid), an opaque ID that hides the number, and groups increasingly use LIDs
+// only. Messages, chats and contacts are stored under the phone number JID
+// whenever the LID's number is known, so a user shows up under a single JID.
+// whatsmeow keeps the LID to phone number mapping in its LID store, filling it
+// from message events, group info, history syncs and notifications.
+
+// phoneJID returns the phone number JID of a user addressed by LID, if known.
+// Other JIDs, and LIDs with an unknown number, are returned as they are.
+// The device part is dropped either way.
+func (a *account) phoneJID(ctx context.Context, jid types.JID) types.JID {
+	jid = jid.ToNonAD()
+	if jid.Server != types.HiddenUserServer {
+		return jid
+	}
+	pn, err := a.client.Store.LIDs.GetPNForLID(ctx, jid)
+	if err != nil {
+		log.Warn().Err(err).Str("lid", jid.String()).Msg("Failed to look up phone number of LID")
+		return jid
+	}
+	if pn.IsEmpty() {
+		return jid
+	}
+	return pn.ToNonAD()
+}
+
+// userLID returns the LID of a user, or an empty JID if it isn't known
+func (a *account) userLID(ctx context.Context, jid types.JID) types.JID {
+	jid = jid.ToNonAD()
+	switch jid.Server {
+	case types.HiddenUserServer:
+		return jid
+	case types.DefaultUserServer:
+	default:
+		return types.JID{}
+	}
+	lid, err := a.client.Store.LIDs.GetLIDForPN(ctx, jid)
+	if err != nil {
+		log.Warn().Err(err).Str("jid", jid.String()).Msg("Failed to look up LID of phone number")
+		return types.JID{}
+	}
+	return lid.ToNonAD()
+}
+
+// canonicalJID is phoneJID for a JID in string form, as stored. Strings that
+// aren't JIDs are returned unchanged.
+func (a *account) canonicalJID(ctx context.Context, jid string) string {
+	if jid == "" {
+		return jid
+	}
+	parsed, err := types.ParseJID(jid)
+	if err != nil {
+		return jid
+	}
+	return a.phoneJID(ctx, parsed).String()
+}
+
+// parseJID parses a JID given as tool input, accepting users by either their
+// LID or phone number, and returns it in the form it is stored under
+func (a *account) parseJID(ctx context.Context, jid string) (types.JID, error) {
+	parsed, err := types.ParseJID(jid)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("invalid JID: %w", err)
+	}
+	return a.phoneJID(ctx, parsed), nil
+}
+
+// sourceSender returns the phone number JID of a message or receipt sender,
+// taking it from the event itself when it carries both forms
+func (a *account) sourceSender(ctx context.Context, source types.MessageSource) types.JID {
+	if source.Sender.Server == types.HiddenUserServer && source.SenderAlt.Server == types.DefaultUserServer {
+		return source.SenderAlt.ToNonAD()
+	}
+	return a.phoneJID(ctx, source.Sender)
+}
+
+// sourceChat returns the JID a message or receipt's chat is stored under. A
+// direct chat addressed by LID is the other side's, who is the sender of
+// incoming messages and the recipient of our own.
+func (a *account) sourceChat(ctx context.Context, source types.MessageSource) types.JID {
+	if source.Chat.Server == types.HiddenUserServer {
+		switch {
+		case !source.IsFromMe && source.Chat.User == source.Sender.User:
+			return a.sourceSender(ctx, source)
+		case source.IsFromMe && source.RecipientAlt.Server == types.DefaultUserServer:
+			return source.RecipientAlt.ToNonAD()
+		}
+	}
+	return a.phoneJID(ctx, source.Chat)
+}
+
+// keySender returns the JID that names the sender of a stored message in the keys
+// of reactions, replies, deletions and read receipts. Chats that address members
+// by LID only know them by LID there, while the stored sender is the phone number
+// shown to the user. usesLIDs is only asked about messages stored before their
+// sender's address was kept.
+func (a *account) keySender(ctx context.Context, msg *Message, usesLIDs func() bool) (types.JID, error) {
+	address := msg.senderAddress
+	if address == "" {
+		address = msg.Sender
+	}
+	sender, err := types.ParseJID(address)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("invalid sender JID: %w", err)
+	}
+	if msg.senderAddress == "" && sender.Server == types.DefaultUserServer && usesLIDs() {
+		if lid := a.userLID(ctx, sender); !lid.IsEmpty() {
+			return lid, nil
+		}
+	}
+	return sender, nil
+}
+
+// chatUsesLIDs returns a function reporting whether chat addresses its members by
+// LID, asking WhatsApp about groups at most once
+func (a *account) chatUsesLIDs(chat types.JID) func() bool {
+	return sync.OnceValue(func() bool {
+		switch chat.Server {
+		case types.HiddenUserServer:
+			return true
+		case types.GroupServer:
+			info, err := a.client.GetGroupInfo(chat)
+			if err != nil {
+				log.Warn().Err(err).Str("group", chat.String()).Msg("Failed to get group addressing mode")
+				return false
+			}
+			return info.AddressingMode == types.AddressingModeLID
+		}
+		return false
+	})
+}
+
+// lookupContact returns the contact info whatsmeow has for a user, which may be
+// stored under either their LID or phone number
+func (a *account) lookupContact(ctx context.Context, jid types.JID) (types.ContactInfo, bool) {
+	jid = jid.ToNonAD()
+	if contact, err := a.client.Store.Contacts.GetContact(ctx, jid); err == nil && contact.Found {
+		return contact, true
+	}
+
+	alt := a.phoneJID(ctx, jid)
+	if alt == jid {
+		alt = a.userLID(ctx, jid)
+	}
+	if alt.IsEmpty() || alt == jid {
+		return types.ContactInfo{}, false
+	}
+	if contact, err := a.client.Store.Contacts.GetContact(ctx, alt); err == nil && contact.Found {
+		return contact, true
+	}
+	return types.ContactInfo{}, false
+}
+
+// contactName returns the name we know a user by, preferring the one saved in
+// the address book over the one they chose themselves
+func (a *account) contactName(ctx context.Context, jid types.JID) string {
+	contact, ok := a.lookupContact(ctx, jid)
+	if !ok {
+		return ""
+	}
+	if contact.FullName != "" {
+		return contact.FullName
+	}
+	return contact.PushName
+}
+
+// mergeStoredLIDs moves what was stored under LIDs before their phone numbers
+// became known over to the phone number JIDs. It runs on every connection, as
+// history syncs and group info fill the LID store in the background.
+func (a *account) mergeStoredLIDs() {
+	ctx := context.Background()
+	lids, err := a.store.storedLIDs(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to find stored LIDs")
+		return
+	}
+
+	merged := 0
+	for _, lid := range lids {
+		pn := a.canonicalJID(ctx, lid)
+		if pn == lid {
+			continue
+		}
+		if err := a.store.mergeJID(ctx, lid, pn); err != nil {
+			log.Error().Err(err).Str("lid", lid).Str("jid", pn).Msg("Failed to merge LID into phone number")
+			continue
+		}
+		merged++
+	}
+	if merged > 0 {
+		log.Info().Int("users", merged).Msg("Merged messages stored under LIDs into phone numbers")
+	}
+}
@@ -0,0 +1,86 @@
+package whatsapp
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// newTestAccount creates an account that isn't linked or connected, with its
+// stores in a temporary directory
+func newTestAccount(t *testing.T) *account {
+	t.Helper()
+	dir := t.TempDir()
+	config := WhatsAppConfig{
+		DeviceDB:  filepath.Join(dir, "whatsapp.db"),
+		MessageDB: filepath.Join(dir, "messages.db"),
+		MediaDir:  filepath.Join(dir, "media"),
+	}
+	w, err := NewWhatsAppMessenger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { w.container.Close() })
+
+	// The device's stores are only set up once it is linked, apart from the LID
+	// mappings shared by every device
+	device := w.container.NewDevice()
+	device.LIDs = w.container.LIDMap
+	a, err := newAccount(defaultAccount, config, device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.store.Close() })
+	return a
+}
+
+func TestKeySender(t *testing.T) {
+	ctx := context.Background()
+	a := newTestAccount(t)
+	const (
+		phone    = "15550000001@s.whatsapp.net"
+		lid      = "123456789012345@lid"
+		unmapped = "15550000002@s.whatsapp.net"
+	)
+	err := a.client.Store.LIDs.PutLIDMapping(ctx,
+		types.NewJID("123456789012345", types.HiddenUserServer), types.NewJID("15550000001", types.DefaultUserServer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		msg      Message
+		usesLIDs bool
+		want     string
+	}{
+		{"phone number group", Message{Sender: phone}, false, phone},
+		{"LID group", Message{Sender: phone}, true, lid},
+		{"LID group, unknown LID", Message{Sender: unmapped}, true, unmapped},
+		{"LID sender", Message{Sender: lid}, true, lid},
+		// The address the message was sent from is kept, so the group isn't asked about
+		{"stored phone number address", Message{Sender: phone, senderAddress: phone}, true, phone},
+		{"stored LID address", Message{Sender: phone, senderAddress: lid}, false, lid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked := false
+			usesLIDs := func() bool {
+				asked = true
+				return tt.usesLIDs
+			}
+			got, err := a.keySender(ctx, &tt.msg, usesLIDs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("keySender = %s, want %s", got, tt.want)
+			}
+			if tt.msg.senderAddress != "" && asked {
+				t.Error("asked whether the chat uses LIDs despite the stored address")
+			}
+		})
+	}
+}
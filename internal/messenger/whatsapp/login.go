@@ -0,0 +1,283 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	qrterminal "github.com/mdp/qrterminal/v3"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+	"rsc.io/qr"
+)
+
+// loginConnectTimeout is how long Login waits for the first connection after
+// the phone accepted the new device
+const loginConnectTimeout = 30 * time.Second
+
+// LoginOptions selects how Login links the device
+type LoginOptions struct {
+	// QRFile, if set, saves each QR code as a PNG image at this path instead of
+	// printing it in the terminal
+	QRFile string
+
+	// PairPhone, if set, links with a pairing code entered on this phone number
+	// instead of a QR code
+	PairPhone string
+}
+
+// Login links a new device to a WhatsApp account under alias. It is interactive,
+// as the user has to scan a QR code or enter a pairing code on their phone. The
+// alias can be left out for the first account, which becomes the default one.
+func (w *WhatsAppMessenger) Login(ctx context.Context, alias string, opts LoginOptions) error {
+	devices, err := w.linkedDevices(ctx)
+	if err != nil {
+		return err
+	}
+	if alias == "" {
+		if len(devices) > 0 {
+			return fmt.Errorf("already logged in, choose an alias with --account to link another account")
+		}
+		alias = defaultAccount
+	}
+	if err := validateAlias(alias); err != nil {
+		return err
+	}
+	if opts.PairPhone != "" {
+		if opts.PairPhone, err = normalizePhone(opts.PairPhone, w.config.DefaultCountryCode); err != nil {
+			return err
+		}
+	}
+	if device, ok := devices[alias]; ok {
+		return fmt.Errorf("account %s is already logged in as %s, run \"multichat logout --account %s\" first", alias, device.ID.ToNonAD(), alias)
+	}
+
+	a, err := newAccount(alias, accountConfig(w.config, alias), w.container.NewDevice())
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.accounts[alias] = a
+	w.mu.Unlock()
+
+	connected := make(chan struct{}, 1)
+	a.client.AddEventHandler(func(evt interface{}) {
+		if _, ok := evt.(*events.Connected); ok {
+			select {
+			case connected <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	qrChan, err := a.client.GetQRChannel(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get QR channel: %w", err)
+	}
+	if err := a.client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	if err := a.pair(ctx, qrChan, opts); err != nil {
+		a.client.Disconnect()
+		return err
+	}
+	if err := w.saveAlias(ctx, alias, *a.client.Store.ID); err != nil {
+		return err
+	}
+
+	// whatsmeow reconnects after pairing, and the session is only usable once
+	// that connection is up
+	select {
+	case <-connected:
+	case <-time.After(loginConnectTimeout):
+		log.Warn().Msg("Device linked, but the first connection is taking long; it will complete on the next start")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	log.Info().Str("account", alias).Str("jid", a.client.Store.GetJID().ToNonAD().String()).Msg("Logged in to WhatsApp")
+	return nil
+}
+
+// pair shows the QR codes or pairing code from qrChan until the phone accepts
+// the device or the login window runs out
+func (a *account) pair(ctx context.Context, qrChan <-chan whatsmeow.QRChannelItem, opts LoginOptions) error {
+	pairingRequested := false
+	for evt := range qrChan {
+		switch {
+		case evt.Event == "code" && opts.PairPhone != "":
+			// The QR channel keeps rotating codes, but one pairing code lasts the whole login window
+			if pairingRequested {
+				continue
+			}
+			pairingRequested = true
+			code, err := a.client.PairPhone(ctx, opts.PairPhone, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+			if err != nil {
+				return fmt.Errorf("failed to request pairing code: %w", err)
+			}
+			log.Info().Str("code", code).Msg("Pairing code received")
+			fmt.Fprintf(os.Stderr, "\nWhatsApp pairing code: %s\n"+
+				"On your phone open WhatsApp > Linked devices > Link a device > Link with phone number instead, and enter the code.\n\n", code)
+		case evt.Event == "code" && opts.QRFile != "":
+			if err := writeQRCode(opts.QRFile, evt.Code); err != nil {
+				return err
+			}
+			log.Info().Str("file", opts.QRFile).Dur("expires_in", evt.Timeout).Msg("QR code saved, scan it with WhatsApp to log in")
+		case evt.Event == "code":
+			log.Info().Msg("QR code received, displaying for WhatsApp scan...")
+			qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
+			log.Info().Msg("Scan the QR code above with WhatsApp to log in")
+		case evt == whatsmeow.QRChannelSuccess:
+			log.Info().Msg("Device linked")
+			return nil
+		case evt.Error != nil:
+			return fmt.Errorf("login failed: %w", evt.Error)
+		default:
+			return fmt.Errorf("login failed: %s", evt.Event)
+		}
+	}
+	return fmt.Errorf("login failed: QR channel closed")
+}
+
+// writeQRCode saves a QR code as a PNG image
+func writeQRCode(path, code string) error {
+	img, err := qr.Encode(code, qr.L)
+	if err != nil {
+		return fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	if err := writeFileAtomic(path, img.PNG()); err != nil {
+		return fmt.Errorf("failed to save QR code: %w", err)
+	}
+	return nil
+}
+
+// Logout unlinks the device of an account and forgets it. The alias can be left
+// out while a single account is linked. Once the last account is gone the device
+// database is deleted; message stores are kept.
+func (w *WhatsAppMessenger) Logout(ctx context.Context, alias string) error {
+	devices, err := w.linkedDevices(ctx)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return fmt.Errorf("not logged in to WhatsApp")
+	}
+	aliases := slices.Sorted(maps.Keys(devices))
+	if alias == "" {
+		if len(aliases) > 1 {
+			return fmt.Errorf("several accounts are logged in, choose one with --account: %s", strings.Join(aliases, ", "))
+		}
+		alias = aliases[0]
+	}
+	device, ok := devices[alias]
+	if !ok {
+		return fmt.Errorf("unknown account %q, logged in accounts: %s", alias, strings.Join(aliases, ", "))
+	}
+	jid := device.ID.ToNonAD()
+
+	a, err := newAccount(alias, accountConfig(w.config, alias), device)
+	if err != nil {
+		return err
+	}
+	if err := a.unlink(ctx); err != nil {
+		return err
+	}
+	if err := w.deleteAlias(ctx, alias); err != nil {
+		return err
+	}
+	log.Info().Str("account", alias).Str("jid", jid.String()).Msg("Logged out of WhatsApp")
+
+	if len(devices) > 1 {
+		return nil
+	}
+
+	// Nothing else lives in the device database, so remove it instead of leaving an empty file
+	if err := w.container.Close(); err != nil {
+		return fmt.Errorf("failed to close device database: %w", err)
+	}
+	w.container = nil
+	for _, suffix := range []string{"", "-wal", "-shm", "-journal"} {
+		if err := os.Remove(w.config.DeviceDB + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove device database: %w", err)
+		}
+	}
+	return nil
+}
+
+// Status reports the linked accounts without connecting, so it can be checked
+// while the MCP server holds the connections
+func (w *WhatsAppMessenger) Status(ctx context.Context) ([]AccountStatus, error) {
+	devices, err := w.linkedDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := []AccountStatus{}
+	for _, alias := range slices.Sorted(maps.Keys(devices)) {
+		device := devices[alias]
+		status := AccountStatus{
+			Alias:        alias,
+			JID:          device.ID.ToNonAD().String(),
+			PushName:     device.PushName,
+			Platform:     device.Platform,
+			BusinessName: device.BusinessName,
+		}
+
+		status.Connected, status.LastSeen, err = w.storedConnectionState(ctx, alias, status.JID)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, status)
+	}
+	return accounts, nil
+}
+
+// storedConnectionState reads the connection state last recorded for an account.
+// Status may run next to the MCP server, so the message store is only read: one
+// that doesn't exist yet, or predates connection states, has nothing to report.
+// A server that crashed never recorded disconnecting, so a connected state is
+// only believed while its heartbeat is recent.
+func (w *WhatsAppMessenger) storedConnectionState(ctx context.Context, alias, jid string) (bool, *time.Time, error) {
+	path := accountConfig(w.config, alias).MessageDB
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil, nil
+	}
+
+	store, err := openMessageStoreReadOnly(path)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to open message store for account %q: %w", alias, err)
+	}
+	defer store.Close()
+
+	var recorded bool
+	err = store.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'connection_state')").
+		Scan(&recorded)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to read message store for account %q: %w", alias, err)
+	}
+	if !recorded {
+		return false, nil, nil
+	}
+
+	connected, lastSeen, err := store.connectionState(ctx, jid)
+	if connected && (lastSeen == nil || time.Since(*lastSeen) > 3*connectionHeartbeat) {
+		connected = false
+	}
+	return connected, lastSeen, err
+}
+
+// recordConnection stores whether we are connected, so Status can report on a
+// server running in another process
+func (a *account) recordConnection(connected bool) {
+	if a.client == nil || a.client.Store.ID == nil {
+		return
+	}
+	if err := a.store.setConnectionState(context.Background(), a.client.Store.ID.ToNonAD().String(), connected, time.Now()); err != nil {
+		log.Error().Err(err).Msg("Failed to record connection state")
+	}
+}
@@ -0,0 +1,52 @@
+package whatsapp
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoredConnectionState(t *testing.T) {
+	ctx := context.Background()
+	const jid = "15551234567@s.whatsapp.net"
+	tests := []struct {
+		name          string
+		connected     bool
+		lastSeen      time.Duration // before now
+		wantConnected bool
+	}{
+		{"connected with a recent heartbeat", true, connectionHeartbeat / 2, true},
+		{"connected with a stale heartbeat", true, 10 * connectionHeartbeat, false},
+		{"disconnected", false, time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &WhatsAppMessenger{config: WhatsAppConfig{MessageDB: filepath.Join(t.TempDir(), "messages.db")}}
+			s, err := newMessageStore(w.config.MessageDB)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lastSeen := time.Now().Add(-tt.lastSeen).Truncate(time.Second)
+			if err := s.setConnectionState(ctx, jid, tt.connected, lastSeen); err != nil {
+				t.Fatal(err)
+			}
+			s.Close()
+
+			connected, gotLastSeen, err := w.storedConnectionState(ctx, defaultAccount, jid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if connected != tt.wantConnected || gotLastSeen == nil || !gotLastSeen.Equal(lastSeen) {
+				t.Errorf("got connected=%v last seen %v, want connected=%v last seen %v", connected, gotLastSeen, tt.wantConnected, lastSeen)
+			}
+		})
+	}
+
+	// Nothing is reported for an account whose message store doesn't exist yet
+	w := &WhatsAppMessenger{config: WhatsAppConfig{MessageDB: filepath.Join(t.TempDir(), "messages.db")}}
+	if connected, lastSeen, err := w.storedConnectionState(ctx, defaultAccount, jid); connected || lastSeen != nil || err != nil {
+		t.Errorf("without a message store got %v, %v, %v; want nothing", connected, lastSeen, err)
+	}
+}
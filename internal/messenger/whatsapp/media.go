@@ -0,0 +1,728 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// mediaKind describes how a kind of media message is uploaded and how large it may be
+type mediaKind struct {
+	upload  whatsmeow.MediaType
+	maxSize int64
+}
+
+// mediaKinds holds WhatsApp's size limits for each kind of media message
+var mediaKinds = map[string]mediaKind{
+	"image":    {whatsmeow.MediaImage, 16 << 20},
+	"video":    {whatsmeow.MediaVideo, 16 << 20},
+	"audio":    {whatsmeow.MediaAudio, 16 << 20},
+	"sticker":  {whatsmeow.MediaImage, 500 << 10},
+	"document": {whatsmeow.MediaDocument, 2 << 30},
+}
+
+// maxInMemoryMedia is the largest file send_media reads into memory. Larger
+// files, which can only be sent as documents, are streamed from disk instead.
+const maxInMemoryMedia = 16 << 20
+
+// thumbnailSize is the longest side of the JPEG previews embedded in image messages
+const thumbnailSize = 72
+
+// voiceNoteMimeType is the only format WhatsApp plays as a voice note
+const voiceNoteMimeType = "audio/ogg; codecs=opus"
+
+// maxInlineImageSize is the largest image download_media returns inline
+const maxInlineImageSize = 1 << 20
+
+// mediaExtensions are the preferred file extensions for common media types;
+// the mime package lists several for most of them in no useful order
+var mediaExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"video/mp4":       ".mp4",
+	"video/3gpp":      ".3gp",
+	"audio/ogg":       ".ogg",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/aac":       ".aac",
+	"application/pdf": ".pdf",
+}
+
+// sendMedia uploads a file and sends it as an image, video, audio, document or sticker message
+func (a *account) sendMedia(ctx context.Context, req SendMediaRequest) (*Message, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := a.verifyRecipient(ctx, req.Recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := loadMedia(req, a.config.UploadDir)
+	if err != nil {
+		return nil, err
+	}
+	name := src.name
+	if req.Filename != "" {
+		name = req.Filename
+	}
+
+	mimeType := req.MimeType
+	if mimeType == "" {
+		mimeType = src.mimeType
+	}
+	if mimeType == "" {
+		head, err := src.head()
+		if err != nil {
+			return nil, err
+		}
+		mimeType = detectMimeType(head, name)
+	}
+
+	mediaType := req.MediaType
+	if mediaType == "" {
+		mediaType = inferMediaType(mimeType)
+		if req.PTT {
+			mediaType = "audio"
+		}
+	}
+	kind, ok := mediaKinds[mediaType]
+	if !ok {
+		return nil, fmt.Errorf("invalid media type %q, expected image, video, audio, document or sticker", mediaType)
+	}
+	if src.size > kind.maxSize {
+		return nil, fmt.Errorf("file is %s, larger than the %s WhatsApp allows for %s messages",
+			formatSize(src.size), formatSize(kind.maxSize), mediaType)
+	}
+
+	switch {
+	case req.PTT && mediaType != "audio":
+		return nil, fmt.Errorf("ptt is only supported for audio")
+	case req.PTT && !strings.HasPrefix(mimeType, "audio/ogg"):
+		return nil, fmt.Errorf("voice notes must be Ogg Opus audio, got %s", mimeType)
+	case mediaType == "sticker" && mimeType != "image/webp":
+		return nil, fmt.Errorf("stickers must be WebP images, got %s", mimeType)
+	case req.Caption != "" && (mediaType == "audio" || mediaType == "sticker"):
+		return nil, fmt.Errorf("%s messages can't have a caption", mediaType)
+	case req.Thumbnail != "" && mediaType != "video":
+		return nil, fmt.Errorf("thumbnail is only supported for videos")
+	}
+
+	// WhatsApp can't make a preview of a video itself, so one has to be given
+	var thumbnail []byte
+	if req.Thumbnail != "" {
+		preview, _, err := decodeBase64Media(req.Thumbnail)
+		if err != nil {
+			return nil, fmt.Errorf("invalid thumbnail: %w", err)
+		}
+		if thumbnail, _, _, err = jpegThumbnail(preview); err != nil {
+			return nil, fmt.Errorf("invalid thumbnail: %w", err)
+		}
+	}
+
+	uploaded, err := a.uploadMedia(ctx, src, kind.upload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload media: %w", err)
+	}
+
+	msg := buildMediaMessage(mediaType, mimeType, name, src.data, thumbnail, uploaded, req)
+
+	sent, err := a.sendAndStore(ctx, jid, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send media: %w", err)
+	}
+
+	log.Info().Str("recipient", jid.String()).Str("media_type", mediaType).Int64("size", src.size).Msg("Media sent")
+	return sent, nil
+}
+
+// uploadMedia encrypts and uploads media, streaming it from disk if it is too
+// large to have been read into memory
+func (a *account) uploadMedia(ctx context.Context, src *mediaSource, mediaType whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	if src.data != nil {
+		return a.client.Upload(ctx, src.data, mediaType)
+	}
+
+	file, err := os.Open(src.path)
+	if err != nil {
+		return whatsmeow.UploadResponse{}, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+	return a.client.UploadReader(ctx, file, nil, mediaType)
+}
+
+// downloadMedia downloads and decrypts the attachment of a stored message into the
+// media directory. Files are named after the SHA-256 of their contents, so each
+// attachment is only downloaded and stored once.
+func (a *account) downloadMedia(ctx context.Context, chatJID, messageID string) (*MediaFile, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := a.parseJID(ctx, chatJID)
+	if err != nil {
+		return nil, err
+	}
+
+	media, err := a.store.messageMedia(ctx, jid.String(), messageID)
+	if err != nil {
+		return nil, err
+	}
+	if media == nil {
+		return nil, fmt.Errorf("no media stored for message %s in chat %s", messageID, jid)
+	}
+
+	file, attachment := describeMedia(media)
+	if attachment == nil {
+		return nil, fmt.Errorf("message %s has an unsupported media type", messageID)
+	}
+	file.MessageID = messageID
+	file.ChatJID = jid.ToNonAD().String()
+
+	// The attachment's hash is known up front, so a previous download can be reused
+	if file.SHA256 != "" {
+		path := filepath.Join(a.config.MediaDir, file.SHA256+mediaExtension(file.MimeType, file.FileName))
+		if info, err := os.Stat(path); err == nil && info.Size() == file.Size {
+			file.Path = path
+			return file, nil
+		}
+	}
+
+	data, err := a.client.Download(ctx, attachment)
+	if errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) || errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410) {
+		return nil, fmt.Errorf("media is no longer available on the WhatsApp servers")
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	file.SHA256 = hex.EncodeToString(sum[:])
+	file.Size = int64(len(data))
+	file.Path = filepath.Join(a.config.MediaDir, file.SHA256+mediaExtension(file.MimeType, file.FileName))
+	if err := writeFileAtomic(file.Path, data); err != nil {
+		return nil, fmt.Errorf("failed to save media: %w", err)
+	}
+
+	log.Info().Str("id", messageID).Str("path", file.Path).Int64("size", file.Size).Msg("Media downloaded")
+	return file, nil
+}
+
+// mediaAttachment is implemented by all the media message types
+type mediaAttachment interface {
+	whatsmeow.DownloadableMessage
+	GetMimetype() string
+	GetFileLength() uint64
+}
+
+// describeMedia reads the metadata of a stored attachment and returns the part of
+// it that can be passed to Download
+func describeMedia(media *waProto.Message) (*MediaFile, mediaAttachment) {
+	file := &MediaFile{}
+	var attachment mediaAttachment
+
+	switch {
+	case media.GetImageMessage() != nil:
+		img := media.GetImageMessage()
+		attachment = img
+		file.MediaType, file.Width, file.Height = "image", img.GetWidth(), img.GetHeight()
+	case media.GetVideoMessage() != nil:
+		video := media.GetVideoMessage()
+		attachment = video
+		file.MediaType, file.Width, file.Height, file.Duration = "video", video.GetWidth(), video.GetHeight(), video.GetSeconds()
+	case media.GetAudioMessage() != nil:
+		audio := media.GetAudioMessage()
+		attachment = audio
+		file.MediaType, file.Duration, file.IsVoice = "audio", audio.GetSeconds(), audio.GetPTT()
+	case media.GetDocumentMessage() != nil:
+		doc := media.GetDocumentMessage()
+		attachment = doc
+		file.MediaType, file.FileName = "document", doc.GetFileName()
+	case media.GetStickerMessage() != nil:
+		sticker := media.GetStickerMessage()
+		attachment = sticker
+		file.MediaType, file.Width, file.Height = "sticker", sticker.GetWidth(), sticker.GetHeight()
+	}
+
+	if attachment != nil {
+		file.MimeType, _, _ = strings.Cut(attachment.GetMimetype(), ";")
+		file.Size = int64(attachment.GetFileLength())
+		if sha := attachment.GetFileSHA256(); len(sha) > 0 {
+			file.SHA256 = hex.EncodeToString(sha)
+		}
+	}
+
+	return file, attachment
+}
+
+// mediaExtension picks a file extension for a MIME type, falling back to the
+// extension of the original file name
+func mediaExtension(mimeType, fileName string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	if ext, ok := mediaExtensions[strings.TrimSpace(mimeType)]; ok {
+		return ext
+	}
+	if ext := filepath.Ext(fileName); ext != "" {
+		return strings.ToLower(ext)
+	}
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// writeFileAtomic writes data to path through a temporary file, so a partially
+// written file is never mistaken for a complete download
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// buildMediaMessage builds the message proto for uploaded media. data is nil for
+// files streamed from disk, which get no preview.
+func buildMediaMessage(mediaType, mimeType, name string, data, thumbnail []byte, uploaded whatsmeow.UploadResponse, req SendMediaRequest) *waProto.Message {
+	var caption *string
+	if req.Caption != "" {
+		caption = proto.String(req.Caption)
+	}
+
+	switch mediaType {
+	case "image":
+		img := &waProto.ImageMessage{
+			Caption:       caption,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}
+		if thumbnail, width, height, err := jpegThumbnail(data); err == nil {
+			img.JPEGThumbnail = thumbnail
+			img.Width = proto.Uint32(uint32(width))
+			img.Height = proto.Uint32(uint32(height))
+		} else {
+			log.Debug().Err(err).Msg("Failed to generate image thumbnail")
+		}
+		return &waProto.Message{ImageMessage: img}
+
+	case "video":
+		return &waProto.Message{VideoMessage: &waProto.VideoMessage{
+			Caption:       caption,
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			JPEGThumbnail: thumbnail,
+		}}
+
+	case "audio":
+		audio := &waProto.AudioMessage{
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			PTT:           proto.Bool(req.PTT),
+		}
+		if req.PTT {
+			audio.Mimetype = proto.String(voiceNoteMimeType)
+		}
+		if strings.HasPrefix(mimeType, "audio/ogg") {
+			if seconds := oggDuration(data); seconds > 0 {
+				audio.Seconds = proto.Uint32(seconds)
+			}
+		}
+		return &waProto.Message{AudioMessage: audio}
+
+	case "sticker":
+		return &waProto.Message{StickerMessage: &waProto.StickerMessage{
+			Mimetype:      proto.String(mimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}}
+	}
+
+	if name == "" {
+		name = "file" + mediaExtension(mimeType, "")
+	}
+	doc := &waProto.DocumentMessage{
+		Caption:       caption,
+		FileName:      proto.String(name),
+		Title:         proto.String(name),
+		Mimetype:      proto.String(mimeType),
+		URL:           proto.String(uploaded.URL),
+		DirectPath:    proto.String(uploaded.DirectPath),
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    proto.Uint64(uploaded.FileLength),
+	}
+	if strings.HasPrefix(mimeType, "image/") {
+		if thumbnail, _, _, err := jpegThumbnail(data); err == nil {
+			doc.JPEGThumbnail = thumbnail
+		}
+	}
+	return &waProto.Message{DocumentMessage: doc}
+}
+
+// mediaSource is the media of a send_media request. Files up to
+// maxInMemoryMedia and base64 data are held in data; larger files are read from
+// path when they are uploaded.
+type mediaSource struct {
+	data []byte
+	path string
+	size int64
+	// name is the file name, if known, and mimeType the type given by a data: URL
+	name     string
+	mimeType string
+}
+
+// head returns the start of the media, enough to sniff its MIME type
+func (src *mediaSource) head() ([]byte, error) {
+	if src.data != nil {
+		return src.data, nil
+	}
+
+	file, err := os.Open(src.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	defer file.Close()
+
+	// http.DetectContentType considers at most the first 512 bytes
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return head[:n], nil
+}
+
+// loadMedia reads the media of a request from its file path, which must be in
+// uploadDir, or base64 data
+func loadMedia(req SendMediaRequest, uploadDir string) (*mediaSource, error) {
+	switch {
+	case req.FilePath != "" && req.Data != "":
+		return nil, fmt.Errorf("provide either file_path or data, not both")
+
+	case req.FilePath != "":
+		path, err := uploadPath(uploadDir, req.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		if info.Size() > mediaKinds["document"].maxSize {
+			return nil, fmt.Errorf("file is %s, larger than any WhatsApp media limit", formatSize(info.Size()))
+		}
+		src := &mediaSource{path: path, size: info.Size(), name: filepath.Base(req.FilePath)}
+		if src.size <= maxInMemoryMedia {
+			if src.data, err = os.ReadFile(path); err != nil {
+				return nil, fmt.Errorf("failed to read file: %w", err)
+			}
+			src.size = int64(len(src.data))
+		}
+		return src, nil
+
+	case req.Data != "":
+		data, mimeType, err := decodeBase64Media(req.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &mediaSource{data: data, size: int64(len(data)), mimeType: mimeType}, nil
+	}
+
+	return nil, fmt.Errorf("either file_path or data is required")
+}
+
+// decodeBase64Media decodes plain base64 or a base64 data: URL, returning the
+// MIME type given by a data: URL
+func decodeBase64Media(encoded string) (data []byte, mimeType string, err error) {
+	encoded = strings.TrimSpace(encoded)
+	if rest, ok := strings.CutPrefix(encoded, "data:"); ok {
+		header, payload, found := strings.Cut(rest, ",")
+		if !found || !strings.HasSuffix(header, ";base64") {
+			return nil, "", fmt.Errorf("data URLs must be base64 encoded")
+		}
+		mimeType = strings.TrimSuffix(header, ";base64")
+		encoded = payload
+	}
+	data, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid base64 data: %w", err)
+	}
+	return data, mimeType, nil
+}
+
+// detectMimeType sniffs the MIME type of media, falling back to the file
+// extension when the content alone is too generic to tell
+func detectMimeType(data []byte, name string) string {
+	mimeType, _, _ := strings.Cut(http.DetectContentType(data), ";")
+	switch mimeType {
+	case "application/ogg":
+		// Ogg files sent through WhatsApp are practically always audio
+		return "audio/ogg"
+	case "application/octet-stream", "text/plain", "application/zip":
+		// Office documents are zip files, and many formats aren't sniffed at all
+		if byExt := mime.TypeByExtension(filepath.Ext(name)); byExt != "" {
+			mimeType, _, _ = strings.Cut(byExt, ";")
+		}
+	}
+	return mimeType
+}
+
+// inferMediaType picks the WhatsApp message type for a MIME type. WebP files are
+// sent as images too; stickers have to be asked for with media_type.
+func inferMediaType(mimeType string) string {
+	switch {
+	case mimeType == "image/jpeg" || mimeType == "image/png" || mimeType == "image/webp":
+		return "image"
+	case mimeType == "video/mp4" || mimeType == "video/3gpp":
+		return "video"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	}
+	return "document"
+}
+
+// jpegThumbnail renders the small preview WhatsApp shows before an image is
+// downloaded, and returns the dimensions of the full image
+func jpegThumbnail(data []byte) (thumbnail []byte, width, height int, err error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	bounds := img.Bounds()
+	width, height = bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, 0, 0, fmt.Errorf("empty image")
+	}
+
+	scale := float64(thumbnailSize) / float64(max(width, height))
+	if scale > 1 {
+		scale = 1
+	}
+	thumbWidth, thumbHeight := max(1, int(float64(width)*scale)), max(1, int(float64(height)*scale))
+
+	// Nearest-neighbour scaling is plenty for a blurred placeholder
+	thumb := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := 0; y < thumbHeight; y++ {
+		for x := 0; x < thumbWidth; x++ {
+			thumb.Set(x, y, img.At(bounds.Min.X+x*width/thumbWidth, bounds.Min.Y+y*height/thumbHeight))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, 0, 0, err
+	}
+	return buf.Bytes(), width, height, nil
+}
+
+// oggDuration reads the length in seconds of an Ogg Opus stream from the granule
+// position of its last page. Opus granule positions always count 48 kHz samples.
+func oggDuration(data []byte) uint32 {
+	i := bytes.LastIndex(data, []byte("OggS"))
+	if i < 0 || len(data) < i+14 {
+		return 0
+	}
+	return uint32(binary.LittleEndian.Uint64(data[i+6:]) / 48000)
+}
+
+// formatSize formats a byte count for error messages
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
+
+// registerMediaTools registers the media MCP tools
+func (w *WhatsAppMessenger) registerMediaTools(mcpServer *server.MCPServer) {
+	// send_media
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "send_media",
+		Description: "Send an image, video, audio, voice note, document or sticker from a local file or base64 data. The message type is inferred from the file's MIME type unless media_type is given.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"recipient": map[string]interface{}{
+					"type":        "string",
+					"description": "Phone number or JID of the recipient. Numbers without a country code use the default country code.",
+				},
+				"file_path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of a local file to send, inside the upload directory (--upload-dir). Relative paths are taken from there.",
+				},
+				"data": map[string]interface{}{
+					"type":        "string",
+					"description": "Base64-encoded file contents (or a data: URL), instead of file_path",
+				},
+				"media_type": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"image", "video", "audio", "document", "sticker"},
+					"description": "Message type; inferred from the MIME type when omitted (e.g. send an image as a document). WebP images are only sent as stickers when this is sticker.",
+				},
+				"mime_type": map[string]interface{}{
+					"type":        "string",
+					"description": "MIME type of the file; sniffed from the contents when omitted",
+				},
+				"caption": map[string]interface{}{
+					"type":        "string",
+					"description": "Caption for images, videos and documents",
+				},
+				"filename": map[string]interface{}{
+					"type":        "string",
+					"description": "File name shown for documents (defaults to the name of file_path)",
+				},
+				"ptt": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Send audio as a voice note (push-to-talk). Requires Ogg Opus audio.",
+				},
+				"thumbnail": map[string]interface{}{
+					"type":        "string",
+					"description": "Preview image for videos as base64 (or a data: URL) of a JPEG, PNG or GIF. Videos sent without one show no preview until downloaded.",
+				},
+			},
+			Required: []string{"recipient"},
+		},
+	}, (*account).handleSendMedia)
+
+	// download_media
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "download_media",
+		Description: "Download the image, video, audio, document or sticker attached to a stored message. Returns the local file path, MIME type, size and dimensions/duration, and can include small images inline.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the chat containing the message",
+				},
+				"message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The ID of the message whose media to download",
+				},
+				"inline": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Also return images and stickers up to 1 MB as image content (default: false)",
+				},
+			},
+			Required: []string{"chat_jid", "message_id"},
+		},
+	}, (*account).handleDownloadMedia)
+}
+
+func (a *account) handleSendMedia(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args SendMediaRequest
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	sent, err := a.sendMedia(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("send media failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(sent)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleDownloadMedia(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChatJID   string `json:"chat_jid"`
+		MessageID string `json:"message_id"`
+		Inline    bool   `json:"inline"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	file, err := a.downloadMedia(ctx, args.ChatJID, args.MessageID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("download media failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(file)
+
+	isImage := file.MediaType == "image" || file.MediaType == "sticker"
+	if args.Inline && isImage && file.Size <= maxInlineImageSize {
+		path, err := resolveWithin(a.config.MediaDir, file.Path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("download media failed: %v", err)), nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("download media failed: %v", err)), nil
+		}
+		return mcp.NewToolResultImage(string(result), base64.StdEncoding.EncodeToString(data), file.MimeType), nil
+	}
+
+	return mcp.NewToolResultText(string(result)), nil
+}
@@ -0,0 +1,88 @@
+package whatsapp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMedia(t *testing.T) {
+	dir := t.TempDir()
+	pdf := []byte("%PDF-1.7\n")
+
+	small := filepath.Join(dir, "small.pdf")
+	if err := os.WriteFile(small, pdf, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	src, err := loadMedia(SendMediaRequest{FilePath: "small.pdf"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src.data, pdf) || src.size != int64(len(pdf)) || src.name != "small.pdf" {
+		t.Errorf("small file loaded as %d bytes of data, size %d, name %q", len(src.data), src.size, src.name)
+	}
+
+	// Files too large to hold in memory are left on disk, with only their start read
+	large := filepath.Join(dir, "large.pdf")
+	f, err := os.Create(large)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Write(pdf)
+	if err == nil {
+		err = f.Truncate(maxInMemoryMedia + 1)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err = loadMedia(SendMediaRequest{FilePath: large}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src.data != nil || src.size != maxInMemoryMedia+1 {
+		t.Errorf("large file loaded as %d bytes of data, size %d; want it streamed", len(src.data), src.size)
+	}
+	head, err := src.head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := detectMimeType(head, src.name); got != "application/pdf" {
+		t.Errorf("large file sniffed as %s, want application/pdf", got)
+	}
+
+	for _, req := range []SendMediaRequest{
+		{},
+		{FilePath: "small.pdf", Data: "JVBERi0xLjcK"},
+		{FilePath: "missing.pdf"},
+		{FilePath: filepath.Join(filepath.Dir(dir), "outside.pdf")},
+	} {
+		if _, err := loadMedia(req, dir); err == nil {
+			t.Errorf("loadMedia(%+v) succeeded, want an error", req)
+		}
+	}
+}
+
+func TestDecodeBase64Media(t *testing.T) {
+	tests := []struct {
+		encoded      string
+		want         string
+		wantMimeType string
+		wantErr      bool
+	}{
+		{encoded: "aGVsbG8=", want: "hello"},
+		{encoded: " aGVsbG8 ", want: "hello"},
+		{encoded: "data:image/png;base64,aGVsbG8=", want: "hello", wantMimeType: "image/png"},
+		{encoded: "data:text/plain,hello", wantErr: true},
+		{encoded: "not base64!", wantErr: true},
+	}
+	for _, tt := range tests {
+		data, mimeType, err := decodeBase64Media(tt.encoded)
+		if (err != nil) != tt.wantErr || string(data) != tt.want || mimeType != tt.wantMimeType {
+			t.Errorf("decodeBase64Media(%q) = %q, %q, %v", tt.encoded, data, mimeType, err)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	// isOnWhatsAppBatchSize is how many numbers a single IsOnWhatsApp query asks about
+	isOnWhatsAppBatchSize = 100
+
+	// maxCheckNumbers limits check_numbers, as WhatsApp frowns upon mass lookups
+	maxCheckNumbers = 1000
+)
+
+// errNotOnWhatsApp is returned when a phone number has no WhatsApp account
+var errNotOnWhatsApp = errors.New("not a WhatsApp user")
+
+// numberingPlan describes the national numbers of a country calling code: how
+// many digits they have after the country code, and the trunk prefix dialled
+// in front of them within the country
+type numberingPlan struct {
+	minLength int
+	maxLength int
+	trunk     string
+}
+
+// numberingPlans covers the calling codes whose number lengths are checked.
+// Numbers with other calling codes only get the generic E.164 checks.
+var numberingPlans = map[string]numberingPlan{
+	"1":   {10, 10, "1"}, // United States, Canada and the rest of NANP
+	"7":   {10, 10, "8"}, // Russia, Kazakhstan
+	"20":  {9, 10, "0"},  // Egypt
+	"27":  {9, 9, "0"},   // South Africa
+	"30":  {10, 10, ""},  // Greece
+	"31":  {9, 9, "0"},   // Netherlands
+	"32":  {8, 9, "0"},   // Belgium
+	"33":  {9, 9, "0"},   // France
+	"34":  {9, 9, ""},    // Spain
+	"36":  {8, 9, "06"},  // Hungary
+	"39":  {6, 11, ""},   // Italy, where the leading 0 of landlines is part of the number
+	"40":  {9, 9, "0"},   // Romania
+	"41":  {9, 9, "0"},   // Switzerland
+	"43":  {4, 13, "0"},  // Austria
+	"44":  {9, 10, "0"},  // United Kingdom
+	"45":  {8, 8, ""},    // Denmark
+	"46":  {7, 9, "0"},   // Sweden
+	"47":  {8, 8, ""},    // Norway
+	"48":  {9, 9, ""},    // Poland
+	"49":  {6, 13, "0"},  // Germany
+	"51":  {8, 9, "0"},   // Peru
+	"52":  {10, 10, ""},  // Mexico
+	"54":  {10, 11, "0"}, // Argentina, where mobiles carry an extra 9
+	"55":  {10, 11, "0"}, // Brazil
+	"56":  {9, 9, ""},    // Chile
+	"57":  {10, 10, ""},  // Colombia
+	"58":  {10, 10, "0"}, // Venezuela
+	"60":  {8, 10, "0"},  // Malaysia
+	"61":  {9, 9, "0"},   // Australia
+	"62":  {8, 12, "0"},  // Indonesia
+	"63":  {10, 10, "0"}, // Philippines
+	"64":  {8, 10, "0"},  // New Zealand
+	"65":  {8, 8, ""},    // Singapore
+	"66":  {8, 9, "0"},   // Thailand
+	"81":  {9, 10, "0"},  // Japan
+	"82":  {8, 10, "0"},  // South Korea
+	"84":  {9, 10, "0"},  // Vietnam
+	"86":  {10, 11, "0"}, // China
+	"90":  {10, 10, "0"}, // Turkey
+	"91":  {10, 10, "0"}, // India
+	"92":  {9, 10, "0"},  // Pakistan
+	"94":  {9, 9, "0"},   // Sri Lanka
+	"98":  {10, 10, "0"}, // Iran
+	"212": {9, 9, "0"},   // Morocco
+	"213": {9, 9, "0"},   // Algeria
+	"216": {8, 8, ""},    // Tunisia
+	"233": {9, 9, "0"},   // Ghana
+	"234": {8, 10, "0"},  // Nigeria
+	"254": {9, 9, "0"},   // Kenya
+	"255": {9, 9, "0"},   // Tanzania
+	"256": {9, 9, "0"},   // Uganda
+	"351": {9, 9, ""},    // Portugal
+	"352": {4, 11, ""},   // Luxembourg
+	"353": {7, 9, "0"},   // Ireland
+	"358": {5, 12, "0"},  // Finland
+	"380": {9, 9, "0"},   // Ukraine
+	"420": {9, 9, ""},    // Czech Republic
+	"421": {9, 9, "0"},   // Slovakia
+	"852": {8, 8, ""},    // Hong Kong
+	"880": {10, 10, "0"}, // Bangladesh
+	"886": {8, 9, "0"},   // Taiwan
+	"966": {9, 9, "0"},   // Saudi Arabia
+	"971": {8, 9, "0"},   // United Arab Emirates
+	"972": {8, 9, "0"},   // Israel
+}
+
+// normalizePhone turns a phone number as people write it into E.164 format,
+// such as +351912345678. Numbers without a + or 00 international prefix are
+// read as national numbers of defaultCountryCode when that is set and they fit
+// its numbering plan, dropping the trunk prefix. Otherwise they must start with
+// the country code.
+func normalizePhone(phone, defaultCountryCode string) (string, error) {
+	var digits strings.Builder
+	for _, r := range strings.TrimSpace(phone) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && digits.Len() == 0:
+			digits.WriteRune(r)
+		case strings.ContainsRune(" -.()/", r):
+		default:
+			return "", fmt.Errorf("invalid phone number %q", phone)
+		}
+	}
+	number := digits.String()
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case defaultCountryCode != "":
+		if national, ok := nationalNumber(number, defaultCountryCode); ok {
+			number = defaultCountryCode + national
+		}
+	}
+	if number == "" {
+		return "", fmt.Errorf("invalid phone number %q", phone)
+	}
+
+	// E.164 numbers are at most 15 digits and country codes never start with 0
+	if number[0] == '0' {
+		if defaultCountryCode == "" {
+			return "", fmt.Errorf("phone number %q has no country code, use international format like +15551234567", phone)
+		}
+		return "", fmt.Errorf("invalid phone number %q for country code %s", phone, defaultCountryCode)
+	}
+	if len(number) > 15 {
+		return "", fmt.Errorf("phone number %q is too long", phone)
+	}
+
+	countryCode, plan, ok := lookupNumberingPlan(number)
+	if !ok {
+		if len(number) < 8 {
+			return "", fmt.Errorf("phone number %q is too short", phone)
+		}
+		return "+" + number, nil
+	}
+
+	national := number[len(countryCode):]
+	// People often keep the trunk prefix when writing a number internationally, like
+	// +44 (0)20... National numbers never start with a 0 trunk prefix, while others
+	// such as NANP's 1 are only dropped when the number is too long with them.
+	if rest, ok := strings.CutPrefix(national, plan.trunk); ok && plan.trunk != "" && plan.fits(rest) &&
+		(strings.HasPrefix(plan.trunk, "0") || !plan.fits(national)) {
+		national = rest
+	}
+	if !plan.fits(national) {
+		expected := fmt.Sprintf("%d", plan.minLength)
+		if plan.maxLength != plan.minLength {
+			expected = fmt.Sprintf("%d to %d", plan.minLength, plan.maxLength)
+		}
+		return "", fmt.Errorf("phone number %q has %d digits after country code +%s, expected %s", phone, len(national), countryCode, expected)
+	}
+	return "+" + countryCode + national, nil
+}
+
+// nationalNumber returns number without its trunk prefix if it can be a national
+// number in the country with countryCode
+func nationalNumber(number, countryCode string) (string, bool) {
+	plan, ok := numberingPlans[countryCode]
+	if !ok {
+		// Without a numbering plan the trunk prefix is assumed to be the common 0
+		if national, ok := strings.CutPrefix(number, "0"); ok {
+			return national, true
+		}
+		return "", false
+	}
+	if plan.trunk != "" {
+		if national, ok := strings.CutPrefix(number, plan.trunk); ok && plan.fits(national) {
+			return national, true
+		}
+	}
+	if plan.fits(number) {
+		return number, true
+	}
+	return "", false
+}
+
+// validateCountryCode checks a configured default country code, which may be empty
+func validateCountryCode(code string) error {
+	if code == "" {
+		return nil
+	}
+	if len(code) > 3 || code[0] == '0' || strings.Trim(code, "0123456789") != "" {
+		return fmt.Errorf("invalid country code %q, expected 1 to 3 digits such as 351", code)
+	}
+	return nil
+}
+
+// lookupNumberingPlan finds the country code a full international number starts with
+func lookupNumberingPlan(number string) (string, numberingPlan, bool) {
+	for length := 1; length <= 3 && length < len(number); length++ {
+		if plan, ok := numberingPlans[number[:length]]; ok {
+			return number[:length], plan, true
+		}
+	}
+	return "", numberingPlan{}, false
+}
+
+// fits reports whether national has a valid length for the numbering plan
+func (p numberingPlan) fits(national string) bool {
+	return len(national) >= p.minLength && len(national) <= p.maxLength
+}
+
+// parseRecipient parses a recipient given either as a JID, including a LID, or
+// as a phone number, normalising phone numbers with the account's default country code
+func (a *account) parseRecipient(ctx context.Context, recipient string) (types.JID, error) {
+	if strings.Contains(recipient, "@") {
+		return a.parseJID(ctx, recipient)
+	}
+
+	phone, err := normalizePhone(recipient, a.config.DefaultCountryCode)
+	if err != nil {
+		return types.JID{}, err
+	}
+	return types.NewJID(strings.TrimPrefix(phone, "+"), types.DefaultUserServer), nil
+}
+
+// verifyRecipient parses a recipient like parseRecipient and makes sure a phone
+// number recipient has a WhatsApp account, returning its canonical JID
+func (a *account) verifyRecipient(ctx context.Context, recipient string) (types.JID, error) {
+	jid, err := a.parseRecipient(ctx, recipient)
+	if err != nil || jid.Server != types.DefaultUserServer {
+		return jid, err
+	}
+
+	phone := "+" + jid.User
+	a.verifiedMu.Lock()
+	verified, ok := a.verified[phone]
+	a.verifiedMu.Unlock()
+	if ok {
+		return verified, nil
+	}
+
+	found, err := a.lookupPhones([]string{phone})
+	if err != nil {
+		return types.JID{}, fmt.Errorf("failed to check whether %s is on WhatsApp: %w", phone, err)
+	}
+	if resp, ok := found[phone]; ok && resp.IsIn {
+		return resp.JID, nil
+	}
+	return types.JID{}, fmt.Errorf("%s is %w", phone, errNotOnWhatsApp)
+}
+
+// lookupPhones asks WhatsApp whether phones, in E.164 format, have an account,
+// keyed by phone number. Numbers found are remembered for verifyRecipient.
+func (a *account) lookupPhones(phones []string) (map[string]types.IsOnWhatsAppResponse, error) {
+	found, err := queryPhones(phones, a.client.IsOnWhatsApp)
+	if err != nil {
+		return nil, err
+	}
+	a.verifiedMu.Lock()
+	defer a.verifiedMu.Unlock()
+	for phone, resp := range found {
+		if resp.IsIn {
+			a.verified[phone] = resp.JID
+		}
+	}
+	return found, nil
+}
+
+// queryPhones runs isOnWhatsApp on phones in batches of isOnWhatsAppBatchSize,
+// keying the responses by the phone number asked about
+func queryPhones(phones []string, isOnWhatsApp func([]string) ([]types.IsOnWhatsAppResponse, error)) (map[string]types.IsOnWhatsAppResponse, error) {
+	found := make(map[string]types.IsOnWhatsAppResponse, len(phones))
+	for batch := range slices.Chunk(phones, isOnWhatsAppBatchSize) {
+		responses, err := isOnWhatsApp(batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, resp := range responses {
+			phone := resp.Query
+			if phone == "" {
+				phone = "+" + resp.JID.User
+			}
+			found[phone] = resp
+		}
+	}
+	return found, nil
+}
+
+// checkNumbers reports for each of numbers whether it is on WhatsApp, and with
+// which JID and business details
+func (a *account) checkNumbers(ctx context.Context, numbers []string) ([]NumberCheck, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("no phone numbers given")
+	}
+	if len(numbers) > maxCheckNumbers {
+		return nil, fmt.Errorf("too many phone numbers, at most %d can be checked at once", maxCheckNumbers)
+	}
+
+	results, phones := numberChecks(numbers, a.config.DefaultCountryCode)
+	found, err := a.lookupPhones(phones)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check phone numbers: %w", err)
+	}
+	applyNumberChecks(results, found)
+
+	log.Info().Int("numbers", len(numbers)).Msg("Checked phone numbers")
+	return results, nil
+}
+
+// numberChecks starts the check_numbers results for numbers, reading each in
+// E.164 format or noting why it couldn't be. It also returns the phone numbers
+// to look up, each once however often it was given.
+func numberChecks(numbers []string, countryCode string) ([]NumberCheck, []string) {
+	results := make([]NumberCheck, len(numbers))
+	var phones []string
+	for i, number := range numbers {
+		results[i].Input = number
+		phone, err := normalizePhone(number, countryCode)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Phone = phone
+		if !slices.Contains(phones, phone) {
+			phones = append(phones, phone)
+		}
+	}
+	return results, phones
+}
+
+// applyNumberChecks fills in results with what the lookup found for their phone numbers
+func applyNumberChecks(results []NumberCheck, found map[string]types.IsOnWhatsAppResponse) {
+	for i := range results {
+		resp, ok := found[results[i].Phone]
+		if !ok || !resp.IsIn {
+			continue
+		}
+		results[i].Registered = true
+		results[i].JID = resp.JID.String()
+		if resp.VerifiedName != nil {
+			results[i].IsBusiness = true
+			results[i].VerifiedName = resp.VerifiedName.Details.GetVerifiedName()
+		}
+	}
+}
+
+// registerPhoneTools registers the phone number lookup MCP tool
+func (w *WhatsAppMessenger) registerPhoneTools(mcpServer *server.MCPServer) {
+	// check_numbers
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "check_numbers",
+		Description: "Check which phone numbers are on WhatsApp, returning for each whether it is registered, its JID, and whether it is a business account with its verified business name",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"phone_numbers": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": fmt.Sprintf("Phone numbers to check, at most %d. Numbers without a country code use the default country code.", maxCheckNumbers),
+				},
+			},
+			Required: []string{"phone_numbers"},
+		},
+	}, (*account).handleCheckNumbers)
+}
+
+func (a *account) handleCheckNumbers(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		PhoneNumbers []string `json:"phone_numbers"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	checks, err := a.checkNumbers(ctx, args.PhoneNumbers)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("check numbers failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(checks)
+	return mcp.NewToolResultText(string(result)), nil
+}
@@ -0,0 +1,193 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow/proto/waVnameCert"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone       string
+		countryCode string
+		want        string
+		wantErr     string
+	}{
+		// National numbers read with the default country code
+		{phone: "0912 345 678", countryCode: "886", want: "+886912345678"},
+		{phone: "912 345 678", countryCode: "351", want: "+351912345678"},
+		{phone: "020 7946 0958", countryCode: "44", want: "+442079460958"},
+		{phone: "(555) 123-4567", countryCode: "1", want: "+15551234567"},
+		{phone: "1 555 123 4567", countryCode: "1", want: "+15551234567"},
+		{phone: "8 912 345 67 89", countryCode: "7", want: "+79123456789"},
+		{phone: "06 30 123 4567", countryCode: "36", want: "+36301234567"},
+		{phone: "030 1234567", countryCode: "49", want: "+49301234567"},
+		{phone: "0612345678", countryCode: "999", want: "+999612345678"},
+
+		// International numbers ignore the default country code
+		{phone: "+351 912 345 678", countryCode: "44", want: "+351912345678"},
+		{phone: "00351912345678", want: "+351912345678"},
+		{phone: "+44 (0)20 7946 0958", want: "+442079460958"},
+		{phone: "+1 1 555 123 4567", want: "+15551234567"},
+		{phone: "+39 06 1234 5678", want: "+390612345678"},
+		{phone: "+999 1234 5678", want: "+99912345678"},
+		{phone: "351912345678", want: "+351912345678"},
+		{phone: " +351.912.345.678 ", want: "+351912345678"},
+
+		// Invalid numbers
+		{phone: "", wantErr: "invalid phone number"},
+		{phone: "+", wantErr: "invalid phone number"},
+		{phone: "abc", wantErr: "invalid phone number"},
+		{phone: "12+34", wantErr: "invalid phone number"},
+		{phone: "0912 345 678", wantErr: "has no country code"},
+		{phone: "0912 345 678", countryCode: "351", wantErr: "for country code 351"},
+		{phone: "0912 345", countryCode: "351", wantErr: "for country code 351"},
+		{phone: "+351 912 345", wantErr: "has 6 digits after country code +351, expected 9"},
+		{phone: "+44 20 7946 0958 12", wantErr: "expected 9 to 10"},
+		{phone: "+1234567890123456", wantErr: "too long"},
+		{phone: "+999 123", wantErr: "too short"},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizePhone(tt.phone, tt.countryCode)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("normalizePhone(%q, %q) = %q, %v; want error containing %q", tt.phone, tt.countryCode, got, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("normalizePhone(%q, %q) = %q, %v; want %q", tt.phone, tt.countryCode, got, err, tt.want)
+		}
+	}
+}
+
+func TestLookupNumberingPlan(t *testing.T) {
+	tests := []struct {
+		number   string
+		wantCode string
+		wantOK   bool
+	}{
+		{"15551234567", "1", true},
+		{"79123456789", "7", true},
+		{"442079460958", "44", true},
+		{"351912345678", "351", true},
+		{"380441234567", "380", true},
+		{"99912345678", "", false},
+		{"1", "", false},
+	}
+
+	for _, tt := range tests {
+		code, plan, ok := lookupNumberingPlan(tt.number)
+		if code != tt.wantCode || ok != tt.wantOK {
+			t.Errorf("lookupNumberingPlan(%q) = %q, %v; want %q, %v", tt.number, code, ok, tt.wantCode, tt.wantOK)
+		}
+		if ok && plan != numberingPlans[code] {
+			t.Errorf("lookupNumberingPlan(%q) returned plan %+v, want %+v", tt.number, plan, numberingPlans[code])
+		}
+	}
+}
+
+func TestValidateCountryCode(t *testing.T) {
+	for _, code := range []string{"", "1", "44", "351"} {
+		if err := validateCountryCode(code); err != nil {
+			t.Errorf("validateCountryCode(%q) = %v, want nil", code, err)
+		}
+	}
+	for _, code := range []string{"0", "044", "1234", "+44", "4a"} {
+		if err := validateCountryCode(code); err == nil {
+			t.Errorf("validateCountryCode(%q) = nil, want an error", code)
+		}
+	}
+}
+
+func TestQueryPhones(t *testing.T) {
+	var phones []string
+	for i := range 250 {
+		phones = append(phones, fmt.Sprintf("+1555%07d", i))
+	}
+
+	var batches []int
+	isOnWhatsApp := func(batch []string) ([]types.IsOnWhatsAppResponse, error) {
+		batches = append(batches, len(batch))
+		var responses []types.IsOnWhatsAppResponse
+		for i, phone := range batch {
+			resp := types.IsOnWhatsAppResponse{Query: phone, JID: types.NewJID(phone[1:], types.DefaultUserServer), IsIn: i%2 == 0}
+			// Responses without the query are matched by their JID
+			if i == 1 {
+				resp.Query = ""
+			}
+			responses = append(responses, resp)
+		}
+		return responses, nil
+	}
+
+	found, err := queryPhones(phones, isOnWhatsApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{100, 100, 50}; !slices.Equal(batches, want) {
+		t.Errorf("got batches of %v, want %v", batches, want)
+	}
+	if len(found) != len(phones) {
+		t.Errorf("got %d responses, want %d", len(found), len(phones))
+	}
+	for i, phone := range phones {
+		if resp, ok := found[phone]; !ok || resp.IsIn != (i%100%2 == 0) {
+			t.Errorf("response for %s = %+v, %v", phone, resp, ok)
+		}
+	}
+
+	// A failed batch fails the whole lookup
+	failed := errors.New("rate limited")
+	batches = nil
+	_, err = queryPhones(phones, func(batch []string) ([]types.IsOnWhatsAppResponse, error) {
+		if len(batches) == 1 {
+			return nil, failed
+		}
+		return isOnWhatsApp(batch)
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("got error %v, want %v", err, failed)
+	}
+}
+
+func TestNumberChecks(t *testing.T) {
+	numbers := []string{"912 345 678", "+351 912 345 678", "abc", "+351 961 234 567", "+351 931 234 567"}
+	results, phones := numberChecks(numbers, "351")
+
+	// The same number given twice is looked up once
+	if want := []string{"+351912345678", "+351961234567", "+351931234567"}; !slices.Equal(phones, want) {
+		t.Errorf("got phones %v, want %v", phones, want)
+	}
+
+	shop := "Loja do Bairro"
+	found := map[string]types.IsOnWhatsAppResponse{
+		"+351912345678": {JID: types.NewJID("351912345678", types.DefaultUserServer), IsIn: true},
+		"+351961234567": {JID: types.NewJID("351961234567", types.DefaultUserServer), IsIn: true,
+			VerifiedName: &types.VerifiedName{Details: &waVnameCert.VerifiedNameCertificate_Details{VerifiedName: &shop}}},
+		"+351931234567": {JID: types.NewJID("351931234567", types.DefaultUserServer), IsIn: false},
+	}
+	applyNumberChecks(results, found)
+
+	want := []NumberCheck{
+		{Input: "912 345 678", Phone: "+351912345678", Registered: true, JID: "351912345678@s.whatsapp.net"},
+		{Input: "+351 912 345 678", Phone: "+351912345678", Registered: true, JID: "351912345678@s.whatsapp.net"},
+		{Input: "abc", Error: results[2].Error},
+		{Input: "+351 961 234 567", Phone: "+351961234567", Registered: true, JID: "351961234567@s.whatsapp.net",
+			IsBusiness: true, VerifiedName: shop},
+		{Input: "+351 931 234 567", Phone: "+351931234567"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("got results\n%+v\nwant\n%+v", results, want)
+	}
+	if !strings.Contains(results[2].Error, "invalid phone number") {
+		t.Errorf("got error %q for a number without digits", results[2].Error)
+	}
+}
@@ -0,0 +1,289 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// chatPresences maps the chat_presence tool states to what WhatsApp sends.
+// Recording a voice note is composing with audio media.
+var chatPresences = map[string]struct {
+	state types.ChatPresence
+	media types.ChatPresenceMedia
+}{
+	"composing": {types.ChatPresenceComposing, types.ChatPresenceMediaText},
+	"recording": {types.ChatPresenceComposing, types.ChatPresenceMediaAudio},
+	"paused":    {types.ChatPresencePaused, types.ChatPresenceMediaText},
+}
+
+// sendChatPresence shows the other side of a chat that we are typing or
+// recording, or clears that again with paused
+func (a *account) sendChatPresence(ctx context.Context, chatJID, state string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	presence, ok := chatPresences[state]
+	if !ok {
+		return fmt.Errorf("invalid state %q, must be composing, recording or paused", state)
+	}
+
+	jid, err := a.parseRecipient(ctx, chatJID)
+	if err != nil {
+		return err
+	}
+
+	if err := a.client.SendChatPresence(jid, presence.state, presence.media); err != nil {
+		return fmt.Errorf("failed to send chat presence: %w", err)
+	}
+
+	log.Debug().Str("chat", jid.String()).Str("state", state).Msg("Chat presence sent")
+	return nil
+}
+
+// setPresence sets whether we appear online to our contacts
+func (a *account) setPresence(ctx context.Context, state string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	presence := types.Presence(state)
+	if presence != types.PresenceAvailable && presence != types.PresenceUnavailable {
+		return fmt.Errorf("invalid state %q, must be available or unavailable", state)
+	}
+
+	if err := a.client.SendPresence(presence); err != nil {
+		return fmt.Errorf("failed to set presence: %w", err)
+	}
+
+	log.Info().Str("state", state).Msg("Presence set")
+	return nil
+}
+
+// subscribePresence asks WhatsApp to send us a contact's presence updates and
+// returns what we know so far. Updates arrive as events and are stored.
+func (a *account) subscribePresence(ctx context.Context, contact string) (*ContactPresence, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := a.parseRecipient(ctx, contact)
+	if err != nil {
+		return nil, err
+	}
+	if jid.Server == types.GroupServer {
+		return nil, fmt.Errorf("presence is only available for contacts, not groups")
+	}
+
+	if err := a.client.SubscribePresence(jid); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to presence: %w", err)
+	}
+	if err := a.store.setPresenceSubscribed(ctx, jid.String()); err != nil {
+		return nil, err
+	}
+
+	log.Info().Str("jid", jid.String()).Msg("Subscribed to presence")
+	return a.getPresence(ctx, contact)
+}
+
+// getPresence returns the last known presence of a contact
+func (a *account) getPresence(ctx context.Context, contact string) (*ContactPresence, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, err := a.parseRecipient(ctx, contact)
+	if err != nil {
+		return nil, err
+	}
+
+	presence, err := a.store.getPresence(ctx, jid.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get presence: %w", err)
+	}
+	if presence == nil {
+		presence = &ContactPresence{JID: jid.String()}
+	}
+	return presence, nil
+}
+
+// handlePresence records a presence update from a contact we subscribed to
+func (a *account) handlePresence(evt *events.Presence) {
+	ctx := context.Background()
+	jid := a.phoneJID(ctx, evt.From).String()
+	if err := a.store.savePresence(ctx, jid, !evt.Unavailable, evt.LastSeen, time.Now()); err != nil {
+		log.Error().Err(err).Str("jid", jid).Msg("Failed to store presence")
+	}
+}
+
+// renewPresenceSubscriptions subscribes again to the contacts we followed, as
+// WhatsApp forgets presence subscriptions when the connection drops
+func (a *account) renewPresenceSubscriptions() {
+	jids, err := a.store.presenceSubscriptions(context.Background())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to load presence subscriptions")
+		return
+	}
+
+	for _, s := range jids {
+		jid, err := types.ParseJID(s)
+		if err != nil {
+			continue
+		}
+		if err := a.client.SubscribePresence(jid); err != nil {
+			log.Warn().Err(err).Str("jid", s).Msg("Failed to renew presence subscription")
+		}
+	}
+}
+
+// registerPresenceTools registers the typing indicator and presence MCP tools
+func (w *WhatsAppMessenger) registerPresenceTools(mcpServer *server.MCPServer) {
+	// send_chat_presence
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "send_chat_presence",
+		Description: "Show a typing or recording indicator in a chat, or clear it with paused. WhatsApp clears the indicator by itself after a while or when a message is sent.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID or phone number of the chat",
+				},
+				"state": map[string]interface{}{
+					"type":        "string",
+					"description": "composing (typing), recording (a voice note) or paused (stopped typing)",
+					"enum":        []string{"composing", "recording", "paused"},
+				},
+			},
+			Required: []string{"chat_jid", "state"},
+		},
+	}, (*account).handleSendChatPresence)
+
+	// set_presence
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "set_presence",
+		Description: "Set whether you appear online to your contacts. WhatsApp only sends contact presence updates while you are available.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"state": map[string]interface{}{
+					"type":        "string",
+					"description": "available (online) or unavailable (offline)",
+					"enum":        []string{"available", "unavailable"},
+				},
+			},
+			Required: []string{"state"},
+		},
+	}, (*account).handleSetPresence)
+
+	// subscribe_presence
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "subscribe_presence",
+		Description: "Start receiving a contact's online and last seen status. Updates arrive in the background and can be read with get_presence.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID or phone number of the contact",
+				},
+			},
+			Required: []string{"jid"},
+		},
+	}, (*account).handleSubscribePresence)
+
+	// get_presence
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "get_presence",
+		Description: "Get the last known online and last seen status of a contact subscribed to with subscribe_presence",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID or phone number of the contact",
+				},
+			},
+			Required: []string{"jid"},
+		},
+	}, (*account).handleGetPresence)
+}
+
+func (a *account) handleSendChatPresence(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChatJID string `json:"chat_jid"`
+		State   string `json:"state"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.sendChatPresence(ctx, args.ChatJID, args.State)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("send chat presence failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Chat presence set to %s", args.State)), nil
+}
+
+func (a *account) handleSetPresence(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		State string `json:"state"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.setPresence(ctx, args.State)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("set presence failed: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Presence set to %s", args.State)), nil
+}
+
+func (a *account) handleSubscribePresence(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		JID string `json:"jid"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	presence, err := a.subscribePresence(ctx, args.JID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("subscribe presence failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(presence)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleGetPresence(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		JID string `json:"jid"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	presence, err := a.getPresence(ctx, args.JID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("get presence failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(presence)
+	return mcp.NewToolResultText(string(result)), nil
+}
@@ -0,0 +1,101 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// reactToMessage sets our reaction to a message, or removes it when emoji is empty
+func (a *account) reactToMessage(ctx context.Context, chatJID, messageID, emoji string) error {
+	if err := a.ensureConnected(); err != nil {
+		return err
+	}
+
+	jid, target, err := a.lookupMessage(ctx, chatJID, messageID)
+	if err != nil {
+		return err
+	}
+
+	// The reaction has to name the target's sender, unless we sent it ourselves
+	sender := types.EmptyJID
+	if !target.IsFromMe {
+		if sender, err = a.keySender(ctx, target, a.chatUsesLIDs(jid)); err != nil {
+			return err
+		}
+	}
+
+	resp, err := a.client.SendMessage(ctx, jid, a.client.BuildReaction(jid, sender, messageID, emoji))
+	if err != nil {
+		return fmt.Errorf("failed to send reaction: %w", err)
+	}
+
+	// As with other sends, whatsmeow doesn't echo our own reaction back as an event
+	err = a.store.saveReaction(ctx, messageReaction{
+		ChatJID:   target.ChatJID,
+		MessageID: messageID,
+		Sender:    a.client.Store.GetJID().ToNonAD().String(),
+		Emoji:     emoji,
+		Timestamp: resp.Timestamp,
+	})
+	if err != nil {
+		log.Error().Err(err).Str("id", messageID).Msg("Failed to store own reaction")
+	}
+
+	log.Info().Str("chat", target.ChatJID).Str("id", messageID).Msg("Reaction sent")
+	return nil
+}
+
+// registerReactionTools registers the reaction MCP tools
+func (w *WhatsAppMessenger) registerReactionTools(mcpServer *server.MCPServer) {
+	// react_to_message
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "react_to_message",
+		Description: "React to a message with an emoji, or remove your reaction by passing an empty emoji",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the chat containing the message",
+				},
+				"message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The ID of the message to react to",
+				},
+				"emoji": map[string]interface{}{
+					"type":        "string",
+					"description": "A single emoji such as 👍, or an empty string to remove your reaction",
+				},
+			},
+			Required: []string{"chat_jid", "message_id", "emoji"},
+		},
+	}, (*account).handleReactToMessage)
+}
+
+func (a *account) handleReactToMessage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChatJID   string `json:"chat_jid"`
+		MessageID string `json:"message_id"`
+		Emoji     string `json:"emoji"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	err := a.reactToMessage(ctx, args.ChatJID, args.MessageID, args.Emoji)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("react to message failed: %v", err)), nil
+	}
+
+	if args.Emoji == "" {
+		return mcp.NewToolResultText("Reaction removed"), nil
+	}
+	return mcp.NewToolResultText("Reaction sent"), nil
+}
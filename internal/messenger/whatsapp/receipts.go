@@ -0,0 +1,260 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/rs/zerolog/log"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// markAsRead sends read receipts for the unread messages of a chat up to and
+// including messageID, or for all of them when messageID is empty
+func (a *account) markAsRead(ctx context.Context, chatJID, messageID string) (*ReadResult, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	var jid types.JID
+	var target *Message
+	var err error
+	if messageID != "" {
+		if jid, target, err = a.lookupMessage(ctx, chatJID, messageID); err != nil {
+			return nil, err
+		}
+	} else {
+		if jid, err = a.parseJID(ctx, chatJID); err != nil {
+			return nil, err
+		}
+	}
+
+	unread, err := a.store.unreadMessages(ctx, jid.String(), target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find unread messages: %w", err)
+	}
+
+	// A receipt names a single sender, which matters for groups, by the
+	// address their messages came from
+	var senders []types.JID
+	bySender := make(map[types.JID][]types.MessageID)
+	usesLIDs := a.chatUsesLIDs(jid)
+	for i := range unread {
+		sender, err := a.keySender(ctx, &unread[i], usesLIDs)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := bySender[sender]; !ok {
+			senders = append(senders, sender)
+		}
+		bySender[sender] = append(bySender[sender], unread[i].ID)
+	}
+
+	now := time.Now()
+	for _, sender := range senders {
+		if err := a.client.MarkRead(bySender[sender], now, jid, sender); err != nil {
+			return nil, fmt.Errorf("failed to send read receipts: %w", err)
+		}
+	}
+
+	var readIDs []string
+	if messageID != "" {
+		readIDs = []string{messageID}
+	}
+	if err := a.store.markChatRead(ctx, jid.String(), readIDs); err != nil {
+		return nil, err
+	}
+
+	result := &ReadResult{ChatJID: jid.String(), MarkedRead: len(unread)}
+	if chat, err := a.store.getChat(ctx, jid.String()); err != nil {
+		log.Warn().Err(err).Str("chat", jid.String()).Msg("Failed to read unread count")
+	} else if chat != nil {
+		result.UnreadCount = chat.UnreadCount
+	}
+
+	log.Info().Str("chat", jid.String()).Int("messages", len(unread)).Msg("Marked as read")
+	return result, nil
+}
+
+// getMessageStatus reports how far one of our messages got with each recipient.
+// In groups every current participant is listed, including those who haven't
+// received the message yet.
+func (a *account) getMessageStatus(ctx context.Context, chatJID, messageID string) (*MessageStatus, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
+
+	jid, msg, err := a.lookupMessage(ctx, chatJID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if !msg.IsFromMe {
+		return nil, fmt.Errorf("delivery status is only tracked for messages you sent")
+	}
+
+	receipts, err := a.store.messageReceipts(ctx, msg.ChatJID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load receipts: %w", err)
+	}
+
+	status := &MessageStatus{MessageID: messageID, ChatJID: msg.ChatJID, SentAt: msg.Timestamp}
+	status.Recipients, status.Status = combineReceipts(receipts, a.messageRecipients(ctx, jid))
+	return status, nil
+}
+
+// combineReceipts lists how far a message got with each of its recipients, and
+// overall, which is as far as it got with all of them. recipients maps each JID
+// receipts may come from to the JID the recipient is reported under; those with
+// no receipts are listed as sent, and receipts from others, such as people who
+// left a group, are listed as they are.
+func combineReceipts(receipts []RecipientStatus, recipients map[string]string) ([]RecipientStatus, string) {
+	combined := []RecipientStatus{}
+	reported := make(map[string]int)
+	for _, r := range receipts {
+		if alias, ok := recipients[r.JID]; ok {
+			r.JID = alias
+		}
+		// A recipient may have sent receipts from both its LID and phone number
+		if i, ok := reported[r.JID]; ok {
+			if statusRank[r.Status] > statusRank[combined[i].Status] {
+				combined[i] = r
+			}
+			continue
+		}
+		reported[r.JID] = len(combined)
+		combined = append(combined, r)
+	}
+	for recipient, alias := range recipients {
+		if _, ok := reported[alias]; !ok && recipient == alias {
+			combined = append(combined, RecipientStatus{JID: recipient, Status: statusSent})
+		}
+	}
+	slices.SortFunc(combined, func(a, b RecipientStatus) int { return strings.Compare(a.JID, b.JID) })
+
+	status := statusPlayed
+	if len(combined) == 0 {
+		status = statusSent
+	}
+	for _, r := range combined {
+		if statusRank[r.Status] < statusRank[status] {
+			status = r.Status
+		}
+	}
+	return combined, status
+}
+
+// messageRecipients returns who a message sent to chat goes to, mapping each
+// JID a recipient's receipts may come from to the JID they are reported under.
+// Group participants can send receipts from either their LID or phone number,
+// and are reported under their phone number when it is known.
+func (a *account) messageRecipients(ctx context.Context, chat types.JID) map[string]string {
+	if chat.Server != types.GroupServer {
+		return map[string]string{chat.String(): chat.String()}
+	}
+
+	info, err := a.client.GetGroupInfo(chat)
+	if err != nil {
+		log.Warn().Err(err).Str("group", chat.String()).Msg("Failed to get group participants, only reporting received receipts")
+		return map[string]string{}
+	}
+
+	own := a.client.Store.GetJID().ToNonAD()
+	ownLID := a.client.Store.GetLID().ToNonAD()
+	recipients := make(map[string]string, len(info.Participants))
+	for _, p := range info.Participants {
+		participant := p.JID.ToNonAD()
+		if participant == own || participant == ownLID {
+			continue
+		}
+		jid := a.participantJID(ctx, p).String()
+		recipients[jid] = jid
+		recipients[participant.String()] = jid
+	}
+	return recipients
+}
+
+// registerReceiptTools registers the read receipt and delivery status MCP tools
+func (w *WhatsAppMessenger) registerReceiptTools(mcpServer *server.MCPServer) {
+	// mark_as_read
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "mark_as_read",
+		Description: "Mark a chat as read, sending read receipts for its unread messages up to a given message or for all of them",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the chat to mark as read",
+				},
+				"message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "Mark messages up to and including this one as read. Defaults to the whole chat.",
+				},
+			},
+			Required: []string{"chat_jid"},
+		},
+	}, (*account).handleMarkAsRead)
+
+	// get_message_status
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "get_message_status",
+		Description: "Get the delivery status of a message you sent: whether each recipient has received, read or played it",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the chat the message was sent to",
+				},
+				"message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The ID of the sent message, as returned by send_message",
+				},
+			},
+			Required: []string{"chat_jid", "message_id"},
+		},
+	}, (*account).handleGetMessageStatus)
+}
+
+func (a *account) handleMarkAsRead(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChatJID   string `json:"chat_jid"`
+		MessageID string `json:"message_id"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	readResult, err := a.markAsRead(ctx, args.ChatJID, args.MessageID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("mark as read failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(readResult)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleGetMessageStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		ChatJID   string `json:"chat_jid"`
+		MessageID string `json:"message_id"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+	}
+
+	status, err := a.getMessageStatus(ctx, args.ChatJID, args.MessageID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("get message status failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(status)
+	return mcp.NewToolResultText(string(result)), nil
+}
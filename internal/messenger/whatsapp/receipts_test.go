@@ -0,0 +1,96 @@
+package whatsapp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCombineReceipts(t *testing.T) {
+	const (
+		alice    = "15550000001@s.whatsapp.net"
+		aliceLID = "11111111111111@lid"
+		bob      = "15550000002@s.whatsapp.net"
+		carol    = "15550000003@s.whatsapp.net"
+		gone     = "15550000009@s.whatsapp.net"
+	)
+	direct := map[string]string{alice: alice}
+	group := map[string]string{alice: alice, aliceLID: alice, bob: bob, carol: carol}
+	receipt := func(jid, status string) RecipientStatus {
+		return RecipientStatus{JID: jid, Status: status}
+	}
+
+	tests := []struct {
+		name       string
+		receipts   []RecipientStatus
+		recipients map[string]string
+		want       []RecipientStatus
+		wantStatus string
+	}{
+		{
+			name:       "direct chat without receipts",
+			recipients: direct,
+			want:       []RecipientStatus{receipt(alice, statusSent)},
+			wantStatus: statusSent,
+		},
+		{
+			name:       "direct chat delivered",
+			receipts:   []RecipientStatus{receipt(alice, statusDelivered)},
+			recipients: direct,
+			want:       []RecipientStatus{receipt(alice, statusDelivered)},
+			wantStatus: statusDelivered,
+		},
+		{
+			name:       "direct chat played",
+			receipts:   []RecipientStatus{receipt(alice, statusPlayed)},
+			recipients: direct,
+			want:       []RecipientStatus{receipt(alice, statusPlayed)},
+			wantStatus: statusPlayed,
+		},
+		{
+			name:       "group waits for the member without receipts",
+			receipts:   []RecipientStatus{receipt(alice, statusPlayed), receipt(bob, statusRead)},
+			recipients: group,
+			want:       []RecipientStatus{receipt(alice, statusPlayed), receipt(bob, statusRead), receipt(carol, statusSent)},
+			wantStatus: statusSent,
+		},
+		{
+			name:       "group is as far as the least advanced member",
+			receipts:   []RecipientStatus{receipt(alice, statusPlayed), receipt(bob, statusRead), receipt(carol, statusDelivered)},
+			recipients: group,
+			want:       []RecipientStatus{receipt(alice, statusPlayed), receipt(bob, statusRead), receipt(carol, statusDelivered)},
+			wantStatus: statusDelivered,
+		},
+		{
+			name: "group member reporting by LID and phone number",
+			receipts: []RecipientStatus{receipt(aliceLID, statusRead), receipt(alice, statusDelivered),
+				receipt(bob, statusRead), receipt(carol, statusRead)},
+			recipients: group,
+			want:       []RecipientStatus{receipt(alice, statusRead), receipt(bob, statusRead), receipt(carol, statusRead)},
+			wantStatus: statusRead,
+		},
+		{
+			name: "member who left the group",
+			receipts: []RecipientStatus{receipt(alice, statusRead), receipt(bob, statusRead),
+				receipt(carol, statusRead), receipt(gone, statusDelivered)},
+			recipients: group,
+			want: []RecipientStatus{receipt(alice, statusRead), receipt(bob, statusRead),
+				receipt(carol, statusRead), receipt(gone, statusDelivered)},
+			wantStatus: statusDelivered,
+		},
+		{
+			name:       "group whose members are unknown",
+			recipients: map[string]string{},
+			want:       []RecipientStatus{},
+			wantStatus: statusSent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status := combineReceipts(tt.receipts, tt.recipients)
+			if !reflect.DeepEqual(got, tt.want) || status != tt.wantStatus {
+				t.Errorf("combineReceipts = %+v, %s; want %+v, %s", got, status, tt.want, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package whatsapp
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriver is go-sqlite3 with the extra SQL functions the message store needs.
+// The default go-sqlite3 build ships FTS4 but not FTS5, so relevance ranking is
+// computed here from FTS4's matchinfo() instead of FTS5's built-in bm25().
+const sqliteDriver = "sqlite3_multichat"
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("bm25", bm25, true)
+		},
+	})
+}
+
+// BM25 tuning parameters, using the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 scores a row from the blob returned by matchinfo(messages_fts, 'pcnalx').
+// Higher scores are better matches.
+func bm25(matchinfo []byte) float64 {
+	if len(matchinfo)%4 != 0 {
+		return 0
+	}
+	info := make([]uint32, len(matchinfo)/4)
+	for i := range info {
+		info[i] = binary.NativeEndian.Uint32(matchinfo[i*4:])
+	}
+	if len(info) < 3 {
+		return 0
+	}
+
+	phrases, cols, rows := int(info[0]), int(info[1]), float64(info[2])
+	avgLen := info[3 : 3+cols]
+	docLen := info[3+cols : 3+2*cols]
+	hits := info[3+2*cols:]
+	if len(hits) < 3*phrases*cols {
+		return 0
+	}
+
+	var score float64
+	for p := 0; p < phrases; p++ {
+		for c := 0; c < cols; c++ {
+			x := hits[3*(c+p*cols):]
+			tf, docsWithHit := float64(x[0]), float64(x[2])
+			if tf == 0 {
+				continue
+			}
+			idf := math.Log(1 + (rows-docsWithHit+0.5)/(docsWithHit+0.5))
+			norm := 1 - bm25B
+			if avgLen[c] > 0 {
+				norm += bm25B * float64(docLen[c]) / float64(avgLen[c])
+			}
+			score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	return score
+}
+
+// ftsQuery turns a user search string into a safe FTS4 MATCH expression.
+// "quoted text" is kept as a phrase, a trailing * makes a prefix query, a
+// leading - excludes a term and an upper-case OR between terms is passed
+// through; everything else is ANDed.
+// Diacritics are folded by the index tokenizer, so "reuniao" matches "reunião".
+func ftsQuery(query string) string {
+	var terms []string
+	pendingOr := false
+
+	addTerm := func(term string, prefix, exclude bool) {
+		term = strings.ReplaceAll(term, `"`, " ")
+		if strings.IndexFunc(term, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) < 0 {
+			return
+		}
+		term = strings.Join(strings.Fields(term), " ")
+		if prefix {
+			term += "*"
+		}
+		switch {
+		case exclude:
+			// NOT is a binary operator, so there has to be something to exclude from
+			if len(terms) == 0 || pendingOr {
+				return
+			}
+			terms = append(terms, "NOT")
+		case pendingOr && len(terms) > 0:
+			terms = append(terms, "OR")
+		}
+		pendingOr = false
+		terms = append(terms, `"`+term+`"`)
+	}
+
+	for rest := strings.TrimSpace(query); rest != ""; rest = strings.TrimSpace(rest) {
+		exclude := strings.HasPrefix(rest, "-")
+		if exclude {
+			rest = rest[1:]
+		}
+
+		if strings.HasPrefix(rest, `"`) {
+			phrase := rest[1:]
+			end := strings.IndexByte(phrase, '"')
+			if end < 0 {
+				end = len(phrase)
+				rest = ""
+			} else {
+				rest = phrase[end+1:]
+			}
+			prefix := strings.HasPrefix(rest, "*")
+			rest = strings.TrimLeft(rest, "*")
+			addTerm(phrase[:end], prefix, exclude)
+			continue
+		}
+
+		end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end < 0 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		if word == "OR" && !exclude {
+			pendingOr = true
+			continue
+		}
+		addTerm(strings.TrimRight(word, "*"), strings.HasSuffix(word, "*"), exclude)
+	}
+
+	return strings.Join(terms, " ")
+}
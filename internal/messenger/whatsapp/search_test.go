@@ -0,0 +1,153 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{``, ``},
+		{`   `, ``},
+		{`hello`, `"hello"`},
+		{`hello world`, `"hello" "world"`},
+		{`"hello world"`, `"hello world"`},
+		{`"hello world"*`, `"hello world*"`},
+		{`"unterminated phrase`, `"unterminated phrase"`},
+		{`meet*`, `"meet*"`},
+		{`lunch -pizza`, `"lunch" NOT "pizza"`},
+		{`lunch -"pizza place"`, `"lunch" NOT "pizza place"`},
+		{`lunch OR dinner`, `"lunch" OR "dinner"`},
+		{`lunch or dinner`, `"lunch" "or" "dinner"`},
+		{`reunião`, `"reunião"`},
+
+		// Exclude-only queries have nothing to exclude from
+		{`-pizza`, ``},
+		{`-pizza -pasta`, ``},
+		{`-pizza lunch`, `"lunch"`},
+		{`lunch OR -pizza`, `"lunch"`},
+
+		// Operators and punctuation on their own are dropped
+		{`OR`, ``},
+		{`OR OR`, ``},
+		{`AND NOT NEAR`, `"AND" "NOT" "NEAR"`},
+		{`*`, ``},
+		{`-`, ``},
+		{`""`, ``},
+		{`" "`, ``},
+		{`( ) ^ :`, ``},
+		{`lunch OR`, `"lunch"`},
+		{`OR lunch`, `"lunch"`},
+		{`a"b`, `"a" "b"`},
+	}
+
+	for _, tt := range tests {
+		if got := ftsQuery(tt.query); got != tt.want {
+			t.Errorf("ftsQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+// matchinfo encodes the 'pcnalx' matchinfo blob of a single column table
+// for one phrase per entry of hits, given as {hits in row, hits in all rows, rows with hits}
+func matchinfo(rows, avgLen, docLen uint32, hits ...[3]uint32) []byte {
+	info := []uint32{uint32(len(hits)), 1, rows, avgLen, docLen}
+	for _, h := range hits {
+		info = append(info, h[:]...)
+	}
+	blob := make([]byte, 4*len(info))
+	for i, v := range info {
+		binary.NativeEndian.PutUint32(blob[4*i:], v)
+	}
+	return blob
+}
+
+func TestBM25(t *testing.T) {
+	// One term occurring once, in one of ten documents of average length
+	got := bm25(matchinfo(10, 5, 5, [3]uint32{1, 1, 1}))
+	want := math.Log(1+(10-1+0.5)/(1+0.5)) * 1 * (bm25K1 + 1) / (1 + bm25K1)
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("bm25 of a single hit = %v, want %v", got, want)
+	}
+
+	ordered := []struct {
+		name          string
+		better, worse []byte
+	}{
+		{"more occurrences", matchinfo(10, 5, 5, [3]uint32{3, 3, 1}), matchinfo(10, 5, 5, [3]uint32{1, 3, 1})},
+		{"rarer term", matchinfo(10, 5, 5, [3]uint32{1, 1, 1}), matchinfo(10, 5, 5, [3]uint32{1, 8, 8})},
+		{"shorter document", matchinfo(10, 5, 2, [3]uint32{1, 1, 1}), matchinfo(10, 5, 20, [3]uint32{1, 1, 1})},
+		{"more terms matched", matchinfo(10, 5, 5, [3]uint32{1, 1, 1}, [3]uint32{1, 1, 1}), matchinfo(10, 5, 5, [3]uint32{1, 1, 1}, [3]uint32{0, 1, 1})},
+	}
+	for _, tt := range ordered {
+		if better, worse := bm25(tt.better), bm25(tt.worse); better <= worse {
+			t.Errorf("%s: scored %v, not above %v", tt.name, better, worse)
+		}
+	}
+
+	for _, blob := range [][]byte{nil, {1, 2, 3}, matchinfo(10, 5, 5)[:8], matchinfo(10, 5, 5, [3]uint32{1, 1, 1})[:20]} {
+		if got := bm25(blob); got != 0 {
+			t.Errorf("bm25(%v) = %v, want 0 for a malformed blob", blob, got)
+		}
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	ts := time.Unix(1700000000, 0)
+	for i, text := range []string{
+		"Reunião amanhã às 10h",
+		"reuniao reuniao reuniao",
+		"lunch at the usual place, then a long walk along the river and back home",
+		"pizza for lunch",
+		"nothing to see here",
+	} {
+		msg := Message{ID: string(rune('A' + i)), ChatJID: testChat, Sender: testSender, Text: text, Timestamp: ts.Add(time.Duration(i) * time.Minute)}
+		if err := s.saveMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"reuniao", []string{"B", "A"}},
+		{"REUNIÃO", []string{"B", "A"}},
+		{"lunch", []string{"D", "C"}},
+		{"lunch -pizza", []string{"C"}},
+		{"pizza OR nothing", []string{"D", "E"}},
+		{"reun*", []string{"B", "A"}},
+		{`"usual place"`, []string{"C"}},
+		{"missing", nil},
+	}
+	for _, tt := range tests {
+		page, err := s.listMessages(ctx, MessageFilter{Query: tt.query, Limit: 10})
+		if err != nil {
+			t.Errorf("search %q: %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, msg := range page.Messages {
+			got = append(got, msg.ID)
+			if msg.Snippet == "" || msg.Score <= 0 {
+				t.Errorf("search %q: message %s has snippet %q and score %v", tt.query, msg.ID, msg.Snippet, msg.Score)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("search %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	if _, err := s.listMessages(ctx, MessageFilter{Query: "-pizza", Limit: 10}); err == nil {
+		t.Error("search with only excluded terms succeeded, want an error")
+	}
+}
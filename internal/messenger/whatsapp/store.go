@@ -0,0 +1,732 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// messageStore persists WhatsApp messages in a local SQLite database.
+// whatsmeow only delivers messages as events, so everything the tools can
+// read has to be recorded here first.
+type messageStore struct {
+	db *sql.DB
+}
+
+// storeUpgrades are applied in order and tracked with PRAGMA user_version
+var storeUpgrades = []string{
+	`CREATE TABLE messages (
+		chat_jid   TEXT    NOT NULL,
+		id         TEXT    NOT NULL,
+		sender     TEXT    NOT NULL,
+		text       TEXT    NOT NULL DEFAULT '',
+		timestamp  INTEGER NOT NULL,
+		is_from_me INTEGER NOT NULL DEFAULT 0,
+		media_type TEXT    NOT NULL DEFAULT '',
+		PRIMARY KEY (chat_jid, id)
+	);
+	CREATE INDEX messages_timestamp_idx ON messages (timestamp);
+	CREATE INDEX messages_chat_timestamp_idx ON messages (chat_jid, timestamp);
+	CREATE INDEX messages_sender_idx ON messages (sender);`,
+	`CREATE TABLE chats (
+		jid               TEXT    PRIMARY KEY,
+		name              TEXT    NOT NULL DEFAULT '',
+		unread_count      INTEGER NOT NULL DEFAULT 0,
+		last_message_time INTEGER NOT NULL DEFAULT 0,
+		state_updated_at  INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE history_sync (
+		sync_type     TEXT    PRIMARY KEY,
+		chunks        INTEGER NOT NULL DEFAULT 0,
+		last_chunk    INTEGER NOT NULL DEFAULT 0,
+		progress      INTEGER NOT NULL DEFAULT 0,
+		conversations INTEGER NOT NULL DEFAULT 0,
+		messages      INTEGER NOT NULL DEFAULT 0,
+		push_names    INTEGER NOT NULL DEFAULT 0,
+		updated_at    INTEGER NOT NULL
+	);`,
+	// Give messages an explicit rowid so the full-text index can reference it safely
+	`DROP INDEX messages_timestamp_idx;
+	DROP INDEX messages_chat_timestamp_idx;
+	DROP INDEX messages_sender_idx;
+	ALTER TABLE messages RENAME TO messages_v2;
+	CREATE TABLE messages (
+		row_id     INTEGER PRIMARY KEY,
+		chat_jid   TEXT    NOT NULL,
+		id         TEXT    NOT NULL,
+		sender     TEXT    NOT NULL,
+		text       TEXT    NOT NULL DEFAULT '',
+		timestamp  INTEGER NOT NULL,
+		is_from_me INTEGER NOT NULL DEFAULT 0,
+		media_type TEXT    NOT NULL DEFAULT '',
+		UNIQUE (chat_jid, id)
+	);
+	INSERT INTO messages (chat_jid, id, sender, text, timestamp, is_from_me, media_type)
+		SELECT chat_jid, id, sender, text, timestamp, is_from_me, media_type FROM messages_v2;
+	DROP TABLE messages_v2;
+	CREATE INDEX messages_timestamp_idx ON messages (timestamp);
+	CREATE INDEX messages_chat_timestamp_idx ON messages (chat_jid, timestamp);
+	CREATE INDEX messages_sender_idx ON messages (sender);
+
+	CREATE VIRTUAL TABLE messages_fts USING fts4(content="messages", text, tokenize=unicode61 "remove_diacritics=2");
+	CREATE TRIGGER messages_fts_before_update BEFORE UPDATE ON messages BEGIN
+		DELETE FROM messages_fts WHERE docid = old.row_id;
+	END;
+	CREATE TRIGGER messages_fts_before_delete BEFORE DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE docid = old.row_id;
+	END;
+	CREATE TRIGGER messages_fts_after_update AFTER UPDATE ON messages BEGIN
+		INSERT INTO messages_fts (docid, text) VALUES (new.row_id, new.text);
+	END;
+	CREATE TRIGGER messages_fts_after_insert AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts (docid, text) VALUES (new.row_id, new.text);
+	END;
+	INSERT INTO messages_fts (messages_fts) VALUES ('rebuild');`,
+	`ALTER TABLE chats ADD COLUMN is_group INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE chats ADD COLUMN archived INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE chats ADD COLUMN pinned INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE chats ADD COLUMN muted_until INTEGER NOT NULL DEFAULT 0;
+	INSERT INTO chats (jid, last_message_time)
+		SELECT chat_jid, MAX(timestamp) FROM messages WHERE true GROUP BY chat_jid
+		ON CONFLICT (jid) DO UPDATE SET last_message_time = MAX(chats.last_message_time, excluded.last_message_time);
+	UPDATE chats SET is_group = 1 WHERE jid LIKE '%@g.us';
+	CREATE INDEX chats_last_message_time_idx ON chats (last_message_time);`,
+	`ALTER TABLE messages ADD COLUMN reply_to_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE messages ADD COLUMN reply_to_sender TEXT NOT NULL DEFAULT '';
+	ALTER TABLE messages ADD COLUMN reply_to_text TEXT NOT NULL DEFAULT '';`,
+	`ALTER TABLE messages ADD COLUMN media BLOB;`,
+	`ALTER TABLE messages ADD COLUMN edited_at INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE messages ADD COLUMN revoked INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE pending_changes (
+		chat_jid   TEXT    NOT NULL,
+		message_id TEXT    NOT NULL,
+		kind       TEXT    NOT NULL,
+		sender     TEXT    NOT NULL,
+		is_from_me INTEGER NOT NULL DEFAULT 0,
+		text       TEXT    NOT NULL DEFAULT '',
+		timestamp  INTEGER NOT NULL,
+		PRIMARY KEY (chat_jid, message_id, kind)
+	);
+	CREATE TABLE group_admins (
+		group_jid TEXT    NOT NULL,
+		jid       TEXT    NOT NULL,
+		synced_at INTEGER NOT NULL,
+		PRIMARY KEY (group_jid, jid)
+	);`,
+	`CREATE TABLE reactions (
+		chat_jid   TEXT    NOT NULL,
+		message_id TEXT    NOT NULL,
+		sender     TEXT    NOT NULL,
+		emoji      TEXT    NOT NULL,
+		timestamp  INTEGER NOT NULL,
+		PRIMARY KEY (chat_jid, message_id, sender)
+	);`,
+	`CREATE TABLE receipts (
+		chat_jid     TEXT    NOT NULL,
+		message_id   TEXT    NOT NULL,
+		recipient    TEXT    NOT NULL,
+		delivered_at INTEGER NOT NULL DEFAULT 0,
+		read_at      INTEGER NOT NULL DEFAULT 0,
+		played_at    INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (chat_jid, message_id, recipient)
+	);`,
+	`CREATE TABLE presence (
+		jid        TEXT    PRIMARY KEY,
+		online     INTEGER NOT NULL DEFAULT 0,
+		last_seen  INTEGER NOT NULL DEFAULT 0,
+		updated_at INTEGER NOT NULL DEFAULT 0,
+		subscribed INTEGER NOT NULL DEFAULT 0
+	);`,
+	`CREATE TABLE connection_state (
+		jid       TEXT    PRIMARY KEY,
+		connected INTEGER NOT NULL DEFAULT 0,
+		last_seen INTEGER NOT NULL DEFAULT 0
+	);`,
+	`ALTER TABLE messages ADD COLUMN sender_address TEXT NOT NULL DEFAULT '';`,
+}
+
+// messageColumns selects a stored message as read by scanMessage
+const messageColumns = "m.row_id, m.chat_jid, m.id, m.sender, m.text, m.timestamp, m.is_from_me, m.media_type, m.edited_at, m.revoked, m.sender_address"
+
+// replyColumns and replyJoin resolve the message a reply quotes, as read by scanReply
+const (
+	replyColumns = "m.reply_to_id, m.reply_to_sender, m.reply_to_text, q.sender, q.text, q.timestamp"
+	replyJoin    = " LEFT JOIN messages q ON q.chat_jid = m.chat_jid AND q.id = m.reply_to_id AND m.reply_to_id <> ''"
+)
+
+// newMessageStore opens (or creates) the message database at path
+func newMessageStore(path string) (*messageStore, error) {
+	db, err := sql.Open(sqliteDriver, fmt.Sprintf("file:%s?_foreign_keys=on&_journal_mode=WAL&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message store: %w", err)
+	}
+
+	s := &messageStore{db: db}
+	if err := s.upgrade(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// openMessageStoreReadOnly opens an existing message database at path for reading,
+// without creating it or upgrading its schema
+func openMessageStoreReadOnly(path string) (*messageStore, error) {
+	db, err := sql.Open(sqliteDriver, fmt.Sprintf("file:%s?mode=ro&_busy_timeout=5000", path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open message store: %w", err)
+	}
+	return &messageStore{db: db}, nil
+}
+
+// upgrade brings the schema up to date
+func (s *messageStore) upgrade(ctx context.Context) error {
+	var version int
+	if err := s.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read message store version: %w", err)
+	}
+
+	for i := version; i < len(storeUpgrades); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin upgrade: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, storeUpgrades[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to upgrade message store to v%d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set message store version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit upgrade: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes the underlying database
+func (s *messageStore) Close() error {
+	return s.db.Close()
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// saveMessage inserts a message, replacing any previous copy with the same ID,
+// applies edits and deletions that arrived before it, and bumps the last activity of its chat
+func (s *messageStore) saveMessage(ctx context.Context, msg Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := saveMessage(ctx, tx, msg); err != nil {
+		return err
+	}
+	if err := touchChat(ctx, tx, msg.ChatJID, msg.Timestamp); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// receiveMessage stores a message delivered as it happened and, the first time
+// it is seen, updates the unread count of its chat
+func (s *messageStore) receiveMessage(ctx context.Context, msg Message) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// WhatsApp may deliver the same message more than once
+	var known bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM messages WHERE chat_jid = ? AND id = ?)",
+		msg.ChatJID, msg.ID).Scan(&known); err != nil {
+		return fmt.Errorf("failed to look up message: %w", err)
+	}
+
+	if err := saveMessage(ctx, tx, msg); err != nil {
+		return err
+	}
+	if err := touchChat(ctx, tx, msg.ChatJID, msg.Timestamp); err != nil {
+		return err
+	}
+	if !known {
+		if err := countUnread(ctx, tx, msg); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// saveMessage upserts msg. An edited or deleted copy keeps its current content,
+// so an older copy, e.g. from history sync, can't bring back what was changed.
+func saveMessage(ctx context.Context, db execer, msg Message) error {
+	var reply QuotedMessage
+	if msg.ReplyTo != nil {
+		reply = *msg.ReplyTo
+	}
+
+	var media []byte
+	if msg.media != nil {
+		var err error
+		if media, err = proto.Marshal(msg.media); err != nil {
+			return fmt.Errorf("failed to encode media: %w", err)
+		}
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO messages (chat_jid, id, sender, text, timestamp, is_from_me, media_type, reply_to_id, reply_to_sender, reply_to_text, media, sender_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_jid, id) DO UPDATE SET
+			sender = excluded.sender,
+			sender_address = CASE WHEN excluded.sender_address <> '' THEN excluded.sender_address ELSE messages.sender_address END,
+			text = CASE WHEN messages.revoked = 1 OR messages.edited_at > 0 THEN messages.text ELSE excluded.text END,
+			timestamp = excluded.timestamp,
+			is_from_me = excluded.is_from_me,
+			media_type = CASE WHEN messages.revoked = 1 OR messages.edited_at > 0 THEN messages.media_type ELSE excluded.media_type END,
+			reply_to_id = excluded.reply_to_id,
+			reply_to_sender = excluded.reply_to_sender,
+			reply_to_text = excluded.reply_to_text,
+			media = CASE WHEN messages.revoked = 1 OR messages.edited_at > 0 THEN messages.media ELSE COALESCE(excluded.media, messages.media) END`,
+		msg.ChatJID, msg.ID, msg.Sender, msg.Text, msg.Timestamp.Unix(), msg.IsFromMe, msg.MediaType,
+		reply.ID, reply.Sender, reply.Text, media, msg.senderAddress)
+	if err != nil {
+		return fmt.Errorf("failed to save message: %w", err)
+	}
+	return applyPendingChanges(ctx, db, msg.ChatJID, msg.ID)
+}
+
+// saveConversation stores a chat with its messages and reactions from a history sync in one transaction
+func (s *messageStore) saveConversation(ctx context.Context, chat Chat, messages []Message, reactions []messageReaction) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO chats (jid, name, is_group, last_message_time)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			name = CASE WHEN excluded.name <> '' THEN excluded.name ELSE chats.name END,
+			is_group = excluded.is_group,
+			last_message_time = MAX(chats.last_message_time, excluded.last_message_time)`,
+		chat.JID, chat.Name, chat.IsGroup, chat.LastActivity.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save chat: %w", err)
+	}
+
+	// History sync describes the chat as of its last activity, which mustn't undo
+	// reads, new messages and settings changes seen live since then
+	_, err = tx.ExecContext(ctx, `
+		UPDATE chats SET unread_count = ?, archived = ?, pinned = ?, muted_until = ?, state_updated_at = ?
+		WHERE jid = ? AND state_updated_at <= ?`,
+		chat.UnreadCount, chat.Archived, chat.Pinned, mutedUntilValue(chat), chat.LastActivity.Unix(),
+		chat.JID, chat.LastActivity.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save chat state: %w", err)
+	}
+
+	for _, msg := range messages {
+		if err := saveMessage(ctx, tx, msg); err != nil {
+			return err
+		}
+	}
+	for _, r := range reactions {
+		if err := saveReaction(ctx, tx, r); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// setConnectionState records whether the device jid is connected as of ts
+func (s *messageStore) setConnectionState(ctx context.Context, jid string, connected bool, ts time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO connection_state (jid, connected, last_seen) VALUES (?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET connected = excluded.connected, last_seen = excluded.last_seen`,
+		jid, connected, ts.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save connection state: %w", err)
+	}
+	return nil
+}
+
+// connectionState returns whether the device jid was last recorded as connected,
+// and when it was last seen connected or disconnecting
+func (s *messageStore) connectionState(ctx context.Context, jid string) (bool, *time.Time, error) {
+	var connected bool
+	var lastSeen int64
+	err := s.db.QueryRowContext(ctx, "SELECT connected, last_seen FROM connection_state WHERE jid = ?", jid).
+		Scan(&connected, &lastSeen)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil, nil
+	} else if err != nil {
+		return false, nil, fmt.Errorf("failed to query connection state: %w", err)
+	}
+	return connected, optionalTime(lastSeen), nil
+}
+
+// recordHistorySync adds the counts of one history sync chunk to its sync type's totals
+func (s *messageStore) recordHistorySync(ctx context.Context, chunk HistorySyncStatus) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO history_sync (sync_type, chunks, last_chunk, progress, conversations, messages, push_names, updated_at)
+		VALUES (?, 1, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (sync_type) DO UPDATE SET
+			chunks = history_sync.chunks + 1,
+			last_chunk = MAX(history_sync.last_chunk, excluded.last_chunk),
+			progress = MAX(history_sync.progress, excluded.progress),
+			conversations = history_sync.conversations + excluded.conversations,
+			messages = history_sync.messages + excluded.messages,
+			push_names = history_sync.push_names + excluded.push_names,
+			updated_at = excluded.updated_at`,
+		chunk.SyncType, chunk.LastChunk, chunk.Progress, chunk.Conversations, chunk.Messages, chunk.PushNames, chunk.UpdatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to record history sync: %w", err)
+	}
+	return nil
+}
+
+// historySyncStatus returns the accumulated totals of every sync type received so far
+func (s *messageStore) historySyncStatus(ctx context.Context) ([]HistorySyncStatus, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT sync_type, chunks, last_chunk, progress, conversations, messages, push_names, updated_at
+		FROM history_sync ORDER BY updated_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history sync status: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := []HistorySyncStatus{}
+	for rows.Next() {
+		var status HistorySyncStatus
+		var updatedAt int64
+		if err := rows.Scan(&status.SyncType, &status.Chunks, &status.LastChunk, &status.Progress,
+			&status.Conversations, &status.Messages, &status.PushNames, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan history sync status: %w", err)
+		}
+		status.UpdatedAt = time.Unix(updatedAt, 0).UTC()
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+// listMessages returns one page of stored messages matching filter. Plain
+// listings run newest first and page by (timestamp, row_id); full-text
+// searches are ordered by relevance and page by offset.
+func (s *messageStore) listMessages(ctx context.Context, filter MessageFilter) (*MessagePage, error) {
+	cursor, err := decodeCursor(filter.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var where []string
+	var args []interface{}
+
+	columns := messageColumns + ", " + replyColumns
+	from := "messages m"
+	order := "m.timestamp DESC, m.row_id DESC"
+	newer := cursor != nil && cursor.Newer
+	offset := 0
+
+	match := ftsQuery(filter.Query)
+	if filter.Query != "" && match == "" {
+		return nil, fmt.Errorf("query %q has no searchable terms", filter.Query)
+	}
+	if match != "" {
+		columns += `, snippet(messages_fts, '**', '**', '…', -1, 16), bm25(matchinfo(messages_fts, 'pcnalx')) AS score`
+		from = "messages_fts JOIN messages m ON m.row_id = messages_fts.docid"
+		order = "score DESC, " + order
+		where = append(where, "messages_fts MATCH ?")
+		args = append(args, match)
+		if cursor != nil {
+			offset = cursor.Offset
+		}
+	} else if cursor != nil {
+		if newer {
+			where = append(where, "(m.timestamp, m.row_id) > (?, ?)")
+			order = "m.timestamp ASC, m.row_id ASC"
+		} else {
+			where = append(where, "(m.timestamp, m.row_id) < (?, ?)")
+		}
+		args = append(args, cursor.Timestamp, cursor.RowID)
+	}
+	if filter.After != nil {
+		where = append(where, "m.timestamp > ?")
+		args = append(args, filter.After.Unix())
+	}
+	if filter.Before != nil {
+		where = append(where, "m.timestamp < ?")
+		args = append(args, filter.Before.Unix())
+	}
+	if filter.SenderJID != "" {
+		where = append(where, "m.sender = ?")
+		args = append(args, filter.SenderJID)
+	}
+	if filter.ChatJID != "" {
+		where = append(where, "m.chat_jid = ?")
+		args = append(args, filter.ChatJID)
+	}
+
+	query := "SELECT " + columns + " FROM " + from + replyJoin
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Fetch one extra row to find out whether another page exists
+	query += " ORDER BY " + order + " LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit+1, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	var keys []pageCursor
+	for rows.Next() {
+		var msg Message
+		var extra []interface{}
+		if match != "" {
+			extra = []interface{}{&msg.Snippet, &msg.Score}
+		}
+		key, err := scanMessageWithReply(rows, &msg, extra...)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	hasMore := len(messages) > filter.Limit
+	if hasMore {
+		messages, keys = messages[:filter.Limit], keys[:filter.Limit]
+	}
+	if err := s.attachReactions(ctx, messages); err != nil {
+		return nil, err
+	}
+
+	page := &MessagePage{Messages: messages}
+	switch {
+	case match != "":
+		if hasMore {
+			page.NextCursor = pageCursor{Offset: offset + filter.Limit}.encode()
+		}
+		if offset > 0 {
+			page.PrevCursor = pageCursor{Offset: max(offset-filter.Limit, 0), Newer: true}.encode()
+		}
+	case len(messages) == 0:
+		if newer {
+			// Nothing newer yet; hand the same cursor back so the caller can poll
+			page.PrevCursor = filter.Cursor
+		}
+	default:
+		if newer {
+			slices.Reverse(page.Messages)
+			slices.Reverse(keys)
+		}
+		first, last := keys[0], keys[len(keys)-1]
+		if newer || hasMore {
+			page.NextCursor = pageCursor{Timestamp: last.Timestamp, RowID: last.RowID}.encode()
+		}
+		if cursor != nil && (!newer || hasMore) {
+			page.PrevCursor = pageCursor{Timestamp: first.Timestamp, RowID: first.RowID, Newer: true}.encode()
+		}
+	}
+
+	return page, nil
+}
+
+// editMessage replaces the text of a stored message after its sender edited it
+func (s *messageStore) editMessage(ctx context.Context, chatJID, id, text string, editedAt time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE messages SET text = ?, edited_at = ? WHERE chat_jid = ? AND id = ?",
+		text, editedAt.Unix(), chatJID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update edited message: %w", err)
+	}
+	return nil
+}
+
+// revokeMessage drops the content of a message its sender deleted for everyone,
+// keeping a placeholder so the conversation still shows where it was
+func (s *messageStore) revokeMessage(ctx context.Context, chatJID, id string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE messages SET text = '', media_type = '', media = NULL, revoked = 1
+		WHERE chat_jid = ? AND id = ?`, chatJID, id)
+	if err != nil {
+		return fmt.Errorf("failed to update revoked message: %w", err)
+	}
+	return nil
+}
+
+// getMessage returns a stored message including its attachment, or nil if it isn't stored
+func (s *messageStore) getMessage(ctx context.Context, chatJID, id string) (*Message, error) {
+	var msg Message
+	var media []byte
+	_, err := scanMessage(s.db.QueryRowContext(ctx,
+		"SELECT "+messageColumns+", m.media FROM messages m WHERE m.chat_jid = ? AND m.id = ?",
+		chatJID, id), &msg, &media)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if media != nil {
+		msg.media = &waProto.Message{}
+		if err := proto.Unmarshal(media, msg.media); err != nil {
+			return nil, fmt.Errorf("failed to decode message media: %w", err)
+		}
+	}
+	return &msg, nil
+}
+
+// messageMedia returns the stored attachment of a message, or nil if the message
+// isn't stored or has no media
+func (s *messageStore) messageMedia(ctx context.Context, chatJID, id string) (*waProto.Message, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, "SELECT media FROM messages WHERE chat_jid = ? AND id = ?", chatJID, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && data == nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query message media: %w", err)
+	}
+
+	var media waProto.Message
+	if err := proto.Unmarshal(data, &media); err != nil {
+		return nil, fmt.Errorf("failed to decode message media: %w", err)
+	}
+	return &media, nil
+}
+
+// messageContext returns the message id in chat with up to count messages on
+// either side of it, in chronological order. It returns nil if the message isn't stored.
+func (s *messageStore) messageContext(ctx context.Context, chatJID, id string, count int) (*MessageContext, error) {
+	var target Message
+	key, err := scanMessageWithReply(s.db.QueryRowContext(ctx,
+		"SELECT "+messageColumns+", "+replyColumns+" FROM messages m"+replyJoin+" WHERE m.chat_jid = ? AND m.id = ?",
+		chatJID, id), &target)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	before, err := s.messagesAround(ctx, chatJID, key, count, false)
+	if err != nil {
+		return nil, err
+	}
+	after, err := s.messagesAround(ctx, chatJID, key, count, true)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []Message{target}
+	for _, messages := range [][]Message{before, targets, after} {
+		if err := s.attachReactions(ctx, messages); err != nil {
+			return nil, err
+		}
+	}
+
+	return &MessageContext{Before: before, Message: targets[0], After: after}, nil
+}
+
+// messagesAround returns up to count messages of a chat directly before or after key, oldest first
+func (s *messageStore) messagesAround(ctx context.Context, chatJID string, key pageCursor, count int, after bool) ([]Message, error) {
+	query := "SELECT " + messageColumns + ", " + replyColumns + " FROM messages m" + replyJoin + " WHERE m.chat_jid = ? AND "
+	if after {
+		query += "(m.timestamp, m.row_id) > (?, ?) ORDER BY m.timestamp ASC, m.row_id ASC LIMIT ?"
+	} else {
+		query += "(m.timestamp, m.row_id) < (?, ?) ORDER BY m.timestamp DESC, m.row_id DESC LIMIT ?"
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, chatJID, key.Timestamp, key.RowID, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query messages: %w", err)
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		var msg Message
+		if _, err := scanMessageWithReply(rows, &msg); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	if !after {
+		slices.Reverse(messages)
+	}
+	return messages, nil
+}
+
+// scanMessage reads messageColumns, followed by any extra columns, into msg and
+// returns the row's position for cursors
+func scanMessage(row scanner, msg *Message, extra ...interface{}) (pageCursor, error) {
+	var key pageCursor
+	var editedAt int64
+	dest := append([]interface{}{&key.RowID, &msg.ChatJID, &msg.ID, &msg.Sender, &msg.Text, &key.Timestamp,
+		&msg.IsFromMe, &msg.MediaType, &editedAt, &msg.Revoked, &msg.senderAddress}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return key, err
+		}
+		return key, fmt.Errorf("failed to scan message: %w", err)
+	}
+	msg.Timestamp = time.Unix(key.Timestamp, 0).UTC()
+	if editedAt > 0 {
+		t := time.Unix(editedAt, 0).UTC()
+		msg.EditedAt = &t
+	}
+	return key, nil
+}
+
+// scanMessageWithReply reads messageColumns followed by replyColumns, and then
+// any extra columns, into msg
+func scanMessageWithReply(row scanner, msg *Message, extra ...interface{}) (pageCursor, error) {
+	var replyID, replySender, replyText string
+	var quotedSender, quotedText sql.NullString
+	var quotedTimestamp sql.NullInt64
+
+	dest := append([]interface{}{&replyID, &replySender, &replyText, &quotedSender, &quotedText, &quotedTimestamp}, extra...)
+	key, err := scanMessage(row, msg, dest...)
+	if err != nil || replyID == "" {
+		return key, err
+	}
+
+	msg.ReplyTo = &QuotedMessage{ID: replyID, Sender: replySender, Text: replyText}
+	if quotedTimestamp.Valid {
+		// The original is stored, prefer it over the copy embedded in the reply
+		ts := time.Unix(quotedTimestamp.Int64, 0).UTC()
+		msg.ReplyTo.Sender = quotedSender.String
+		msg.ReplyTo.Text = quotedText.String
+		msg.ReplyTo.Timestamp = &ts
+	}
+	return key, nil
+}
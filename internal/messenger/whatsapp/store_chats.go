@@ -0,0 +1,353 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// mutedForever is stored in chats.muted_until for chats muted without an end time
+const mutedForever = -1
+
+// chatColumns selects a chat together with its most recent message
+const chatColumns = `
+	c.jid, c.name, c.is_group, c.unread_count, c.last_message_time, c.archived, c.pinned, c.muted_until,
+	m.chat_jid, m.id, m.sender, m.text, m.timestamp, m.is_from_me, m.media_type
+	FROM chats c
+	LEFT JOIN messages m ON m.row_id = (
+		SELECT row_id FROM messages WHERE chat_jid = c.jid ORDER BY timestamp DESC, row_id DESC LIMIT 1
+	)`
+
+// touchChat makes sure a chat exists and moves its last activity forward to ts
+func touchChat(ctx context.Context, db execer, jid string, ts time.Time) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO chats (jid, is_group, last_message_time)
+		VALUES (?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			last_message_time = MAX(chats.last_message_time, excluded.last_message_time)`,
+		jid, strings.HasSuffix(jid, "@"+types.GroupServer), ts.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to update chat: %w", err)
+	}
+	return nil
+}
+
+// setChatName stores the display name of a chat
+func (s *messageStore) setChatName(ctx context.Context, jid, name string) error {
+	return s.setChatColumn(ctx, jid, "name", name, false)
+}
+
+// setChatArchived stores whether a chat is archived
+func (s *messageStore) setChatArchived(ctx context.Context, jid string, archived bool) error {
+	return s.setChatColumn(ctx, jid, "archived", archived, true)
+}
+
+// setChatPinned stores whether a chat is pinned
+func (s *messageStore) setChatPinned(ctx context.Context, jid string, pinned bool) error {
+	return s.setChatColumn(ctx, jid, "pinned", pinned, true)
+}
+
+// setChatMuted stores the mute state of a chat. A nil until with muted set means forever.
+func (s *messageStore) setChatMuted(ctx context.Context, jid string, muted bool, until *time.Time) error {
+	return s.setChatColumn(ctx, jid, "muted_until", mutedUntilValue(Chat{Muted: muted, MutedUntil: until}), true)
+}
+
+// setChatColumn upserts a single column of a chat; column is never user input.
+// Columns that are part of the chat's state also mark it as changed live, which
+// history sync then leaves alone.
+func (s *messageStore) setChatColumn(ctx context.Context, jid, column string, value interface{}, state bool) error {
+	var updatedAt int64
+	if state {
+		updatedAt = time.Now().Unix()
+	}
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO chats (jid, is_group, %[1]s, state_updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			%[1]s = excluded.%[1]s,
+			state_updated_at = MAX(chats.state_updated_at, excluded.state_updated_at)`, column),
+		jid, strings.HasSuffix(jid, "@"+types.GroupServer), value, updatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update chat %s: %w", column, err)
+	}
+	return nil
+}
+
+// countUnread updates the unread count of a chat for a newly received message.
+// Incoming messages add to it, while a message we sent from another device means
+// the chat was open there and has been read.
+func countUnread(ctx context.Context, db execer, msg Message) error {
+	query := "UPDATE chats SET unread_count = unread_count + 1, state_updated_at = ? WHERE jid = ?"
+	if msg.IsFromMe {
+		query = "UPDATE chats SET unread_count = 0, state_updated_at = ? WHERE jid = ?"
+	}
+	if _, err := db.ExecContext(ctx, query, time.Now().Unix(), msg.ChatJID); err != nil {
+		return fmt.Errorf("failed to update unread count: %w", err)
+	}
+	return nil
+}
+
+// markChatRead records that a chat has been read up to the newest of readIDs.
+// Incoming messages after that stay unread; with no IDs the whole chat is read.
+func (s *messageStore) markChatRead(ctx context.Context, chatJID string, readIDs []string) error {
+	query := "UPDATE chats SET unread_count = 0, state_updated_at = ? WHERE jid = ?"
+	args := []interface{}{time.Now().Unix(), chatJID}
+	if len(readIDs) > 0 {
+		// If none of the read messages are stored they must be the newest ones,
+		// so an unknown position counts as everything being read
+		query = `
+			UPDATE chats SET unread_count = (
+				SELECT COUNT(*) FROM messages
+				WHERE chat_jid = chats.jid AND is_from_me = 0 AND timestamp > COALESCE((
+					SELECT MAX(timestamp) FROM messages WHERE chat_jid = chats.jid AND id IN (` +
+			strings.TrimSuffix(strings.Repeat("?, ", len(readIDs)), ", ") + `)
+				), 9223372036854775807)
+			), state_updated_at = ?
+			WHERE jid = ?`
+		args = nil
+		for _, id := range readIDs {
+			args = append(args, id)
+		}
+		args = append(args, time.Now().Unix(), chatJID)
+	}
+
+	if _, err := s.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to mark chat as read: %w", err)
+	}
+	return nil
+}
+
+// markChatUnread flags a chat as unread, as WhatsApp's "mark as unread" does
+func (s *messageStore) markChatUnread(ctx context.Context, chatJID string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE chats SET unread_count = MAX(unread_count, 1), state_updated_at = ? WHERE jid = ?",
+		time.Now().Unix(), chatJID)
+	if err != nil {
+		return fmt.Errorf("failed to mark chat as unread: %w", err)
+	}
+	return nil
+}
+
+// unreadMessages returns the incoming messages of a chat that are still unread,
+// newest first, optionally only those up to and including target. The unread
+// count can miss messages received while we were offline, so an incoming target
+// is returned even when the count says the chat has been read.
+func (s *messageStore) unreadMessages(ctx context.Context, chatJID string, target *Message) ([]Message, error) {
+	var unread int
+	err := s.db.QueryRowContext(ctx, "SELECT unread_count FROM chats WHERE jid = ?", chatJID).Scan(&unread)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to query unread count: %w", err)
+	}
+
+	messages := []Message{}
+	if unread > 0 {
+		// The unread messages are the newest incoming ones, of which only those
+		// up to the target are wanted
+		query := "SELECT " + messageColumns + ` FROM (
+			SELECT * FROM messages WHERE chat_jid = ? AND is_from_me = 0
+			ORDER BY timestamp DESC, row_id DESC LIMIT ?
+		) m`
+		args := []interface{}{chatJID, unread}
+		if target != nil {
+			query += " WHERE (m.timestamp, m.row_id) <= (SELECT timestamp, row_id FROM messages WHERE chat_jid = ? AND id = ?)"
+			args = append(args, chatJID, target.ID)
+		}
+		query += " ORDER BY m.timestamp DESC, m.row_id DESC"
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query unread messages: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var msg Message
+			if _, err := scanMessage(rows, &msg); err != nil {
+				return nil, err
+			}
+			messages = append(messages, msg)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read unread messages: %w", err)
+		}
+	}
+
+	if len(messages) == 0 && target != nil && !target.IsFromMe {
+		messages = append(messages, *target)
+	}
+	return messages, nil
+}
+
+// listChats returns one page of chats ordered by most recent activity, optionally
+// only those with unread messages. The JID breaks ties so pages don't shuffle between calls.
+func (s *messageStore) listChats(ctx context.Context, limit int, cursorToken string, unreadOnly bool) (*ChatPage, error) {
+	cursor, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var where []string
+	var args []interface{}
+	order := "c.last_message_time DESC, c.jid ASC"
+	newer := cursor != nil && cursor.Newer
+	if cursor != nil {
+		if newer {
+			where = append(where, "(c.last_message_time > ? OR (c.last_message_time = ? AND c.jid < ?))")
+			order = "c.last_message_time ASC, c.jid DESC"
+		} else {
+			where = append(where, "(c.last_message_time < ? OR (c.last_message_time = ? AND c.jid > ?))")
+		}
+		args = append(args, cursor.Timestamp, cursor.Timestamp, cursor.JID)
+	}
+	if unreadOnly {
+		where = append(where, "c.unread_count > 0")
+	}
+
+	query := "SELECT " + chatColumns
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Fetch one extra row to find out whether another page exists
+	query += " ORDER BY " + order + " LIMIT ?"
+	args = append(args, limit+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chats: %w", err)
+	}
+	defer rows.Close()
+
+	chats := []Chat{}
+	for rows.Next() {
+		chat, err := scanChat(rows)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, *chat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read chats: %w", err)
+	}
+
+	hasMore := len(chats) > limit
+	if hasMore {
+		chats = chats[:limit]
+	}
+	if newer {
+		slices.Reverse(chats)
+	}
+
+	page := &ChatPage{Chats: chats}
+	if len(chats) == 0 {
+		return page, nil
+	}
+	first, last := chats[0], chats[len(chats)-1]
+	if newer || hasMore {
+		page.NextCursor = pageCursor{Timestamp: last.LastActivity.Unix(), JID: last.JID}.encode()
+	}
+	if cursor != nil && (!newer || hasMore) {
+		page.PrevCursor = pageCursor{Timestamp: first.LastActivity.Unix(), JID: first.JID, Newer: true}.encode()
+	}
+
+	return page, nil
+}
+
+// getChat returns a single chat, or nil if nothing is known about it
+func (s *messageStore) getChat(ctx context.Context, jid string) (*Chat, error) {
+	chat, err := scanChat(s.db.QueryRowContext(ctx, "SELECT "+chatColumns+" WHERE c.jid = ?", jid))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return chat, err
+}
+
+// lastInteractions returns when we last exchanged messages with each contact,
+// either in their direct chat or through their messages in groups
+func (s *messageStore) lastInteractions(ctx context.Context) (map[string]time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT jid, MAX(ts) FROM (
+			SELECT jid, last_message_time AS ts FROM chats WHERE is_group = 0 AND last_message_time > 0
+			UNION ALL
+			SELECT sender, MAX(timestamp) FROM messages WHERE is_from_me = 0 GROUP BY sender
+		) GROUP BY jid`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last interactions: %w", err)
+	}
+	defer rows.Close()
+
+	interactions := make(map[string]time.Time)
+	for rows.Next() {
+		var jid string
+		var ts int64
+		if err := rows.Scan(&jid, &ts); err != nil {
+			return nil, fmt.Errorf("failed to scan last interaction: %w", err)
+		}
+		interactions[jid] = time.Unix(ts, 0).UTC()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read last interactions: %w", err)
+	}
+	return interactions, nil
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanChat(row scanner) (*Chat, error) {
+	var chat Chat
+	var lastActivity, mutedUntil int64
+	var msgChat, msgID, msgSender, msgText, msgMediaType sql.NullString
+	var msgTimestamp sql.NullInt64
+	var msgFromMe sql.NullBool
+
+	err := row.Scan(&chat.JID, &chat.Name, &chat.IsGroup, &chat.UnreadCount, &lastActivity,
+		&chat.Archived, &chat.Pinned, &mutedUntil,
+		&msgChat, &msgID, &msgSender, &msgText, &msgTimestamp, &msgFromMe, &msgMediaType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to scan chat: %w", err)
+	}
+
+	chat.LastActivity = time.Unix(lastActivity, 0).UTC()
+	switch {
+	case mutedUntil == mutedForever:
+		chat.Muted = true
+	case mutedUntil > time.Now().Unix():
+		chat.Muted = true
+		until := time.Unix(mutedUntil, 0).UTC()
+		chat.MutedUntil = &until
+	}
+
+	if msgID.Valid {
+		chat.LastMessage = &Message{
+			ID:        msgID.String,
+			ChatJID:   msgChat.String,
+			Sender:    msgSender.String,
+			Text:      msgText.String,
+			Timestamp: time.Unix(msgTimestamp.Int64, 0).UTC(),
+			IsFromMe:  msgFromMe.Bool,
+			MediaType: msgMediaType.String,
+		}
+	}
+
+	return &chat, nil
+}
+
+// mutedUntilValue converts a chat's mute state to the muted_until column value
+func mutedUntilValue(chat Chat) int64 {
+	switch {
+	case !chat.Muted:
+		return 0
+	case chat.MutedUntil == nil:
+		return mutedForever
+	default:
+		return chat.MutedUntil.Unix()
+	}
+}
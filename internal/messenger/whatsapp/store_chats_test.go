@@ -0,0 +1,162 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// receiveTestMessages stores incoming messages M1..Mn in chat, a second apart
+func receiveTestMessages(t *testing.T, s *messageStore, chat string, n int, base time.Time) {
+	t.Helper()
+	for i := 1; i <= n; i++ {
+		id := fmt.Sprintf("M%d", i)
+		msg := Message{ID: id, ChatJID: chat, Sender: testOther, Text: id, Timestamp: base.Add(time.Duration(i) * time.Second)}
+		if err := s.receiveMessage(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkUnread checks the unread count of a chat
+func checkUnread(t *testing.T, s *messageStore, chat string, want int) {
+	t.Helper()
+	got, err := s.getChat(context.Background(), chat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.UnreadCount != want {
+		t.Errorf("unread count of %s = %v, want %d", chat, got, want)
+	}
+}
+
+func TestCountUnread(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	base := time.Unix(1700000000, 0)
+
+	receiveTestMessages(t, s, testChat, 3, base)
+	checkUnread(t, s, testChat, 3)
+
+	// A message delivered again isn't counted twice
+	if err := s.receiveMessage(ctx, Message{ID: "M2", ChatJID: testChat, Sender: testOther, Text: "M2", Timestamp: base.Add(2 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	checkUnread(t, s, testChat, 3)
+
+	// Replying from another device means the chat was read there
+	if err := s.receiveMessage(ctx, Message{ID: "R", ChatJID: testChat, Sender: testSender, IsFromMe: true, Text: "reply", Timestamp: base.Add(time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	checkUnread(t, s, testChat, 0)
+
+	if err := s.receiveMessage(ctx, Message{ID: "M4", ChatJID: testChat, Sender: testOther, Text: "M4", Timestamp: base.Add(2 * time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	checkUnread(t, s, testChat, 1)
+
+	// Marking as unread flags a read chat without hiding messages already counted
+	if err := s.markChatUnread(ctx, testChat); err != nil {
+		t.Fatal(err)
+	}
+	checkUnread(t, s, testChat, 1)
+	if err := s.markChatRead(ctx, testChat, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.markChatUnread(ctx, testChat); err != nil {
+		t.Fatal(err)
+	}
+	checkUnread(t, s, testChat, 1)
+}
+
+func TestMarkChatRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		readIDs []string
+		want    int
+	}{
+		{"whole chat", nil, 0},
+		{"up to a message", []string{"M2"}, 3},
+		{"newest of several messages", []string{"M1", "M4", "M3"}, 1},
+		{"newest message", []string{"M5"}, 0},
+		// Receipts for messages that aren't stored are for ones newer than what we have
+		{"unknown message", []string{"X"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			receiveTestMessages(t, s, testChat, 5, time.Unix(1700000000, 0))
+			if err := s.markChatRead(context.Background(), testChat, tt.readIDs); err != nil {
+				t.Fatal(err)
+			}
+			checkUnread(t, s, testChat, tt.want)
+		})
+	}
+}
+
+func TestUnreadMessages(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	base := time.Unix(1700000000, 0)
+
+	// We answered M2, and M1 and M2 were read on the phone, leaving M3 to M5 unread
+	receiveTestMessages(t, s, testChat, 5, base)
+	if err := s.receiveMessage(ctx, Message{ID: "OWN", ChatJID: testChat, Sender: testSender, IsFromMe: true, Timestamp: base.Add(2 * time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.markChatRead(ctx, testChat, []string{"M2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(messages []Message) []string {
+		ids := []string{}
+		for _, msg := range messages {
+			ids = append(ids, msg.ID)
+		}
+		return ids
+	}
+	tests := []struct {
+		name   string
+		target string
+		want   []string
+	}{
+		{"all", "", []string{"M5", "M4", "M3"}},
+		{"up to a message", "M4", []string{"M4", "M3"}},
+		{"up to the oldest unread", "M3", []string{"M3"}},
+		// A message counted as read still gets a receipt when asked for
+		{"up to a read message", "M1", []string{"M1"}},
+		{"up to our own earlier message", "OWN", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var target *Message
+			if tt.target != "" {
+				target = mustGetMessage(t, s, testChat, tt.target)
+			}
+			unread, err := s.unreadMessages(ctx, testChat, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(unread); !slices.Equal(got, tt.want) {
+				t.Errorf("unreadMessages = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// mark_as_read up to M4 leaves only the newer M5 unread
+	target := mustGetMessage(t, s, testChat, "M4")
+	if err := s.markChatRead(ctx, testChat, []string{target.ID}); err != nil {
+		t.Fatal(err)
+	}
+	checkUnread(t, s, testChat, 1)
+	unread, err := s.unreadMessages(ctx, testChat, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(unread); !slices.Equal(got, []string{"M5"}) {
+		t.Errorf("after marking up to M4, unreadMessages = %v, want [M5]", got)
+	}
+}
@@ -0,0 +1,102 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Kinds of pendingChange
+const (
+	changeEdit   = "edit"
+	changeRevoke = "revoke"
+)
+
+// pendingChange is an edit or deletion of a message by someone else's device.
+// WhatsApp doesn't order these after the message they change, so they are kept
+// until the original is stored and only then checked and applied. Changes whose
+// message doesn't arrive within revokeWindow are dropped.
+type pendingChange struct {
+	ChatJID   string
+	MessageID string
+	Kind      string
+	Sender    string
+	IsFromMe  bool
+	Text      string
+	Timestamp time.Time
+}
+
+// recordChange stores an edit or deletion and applies it right away if the
+// message it changes is already stored
+func (s *messageStore) recordChange(ctx context.Context, change pendingChange) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Messages are only deleted for everyone within revokeWindow of being sent,
+	// so changes older than that are for messages that will never arrive
+	_, err = tx.ExecContext(ctx, "DELETE FROM pending_changes WHERE timestamp < ?", time.Now().Add(-revokeWindow).Unix())
+	if err != nil {
+		return fmt.Errorf("failed to prune message changes: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO pending_changes (chat_jid, message_id, kind, sender, is_from_me, text, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (chat_jid, message_id, kind) DO UPDATE SET
+			sender = excluded.sender,
+			is_from_me = excluded.is_from_me,
+			text = excluded.text,
+			timestamp = excluded.timestamp
+		WHERE excluded.timestamp >= pending_changes.timestamp`,
+		change.ChatJID, change.MessageID, change.Kind, change.Sender, change.IsFromMe,
+		change.Text, change.Timestamp.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save message change: %w", err)
+	}
+	if err := applyPendingChanges(ctx, tx, change.ChatJID, change.MessageID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// applyPendingChanges applies the recorded edit and deletion of a stored message,
+// if they come from its sender (or, for deletions, a cached admin of its group),
+// and then discards them. Changes to messages that aren't stored yet are left in place.
+func applyPendingChanges(ctx context.Context, db execer, chatJID, id string) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE messages SET text = '', media_type = '', media = NULL, revoked = 1
+		FROM pending_changes p
+		WHERE messages.chat_jid = ?1 AND messages.id = ?2
+			AND p.chat_jid = messages.chat_jid AND p.message_id = messages.id AND p.kind = 'revoke'
+			AND (p.sender = messages.sender OR (p.is_from_me AND messages.is_from_me)
+				OR EXISTS (SELECT 1 FROM group_admins a WHERE a.group_jid = messages.chat_jid AND a.jid = p.sender))`,
+		chatJID, id)
+	if err != nil {
+		return fmt.Errorf("failed to apply message deletion: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		UPDATE messages SET text = p.text, edited_at = p.timestamp
+		FROM pending_changes p
+		WHERE messages.chat_jid = ?1 AND messages.id = ?2 AND messages.revoked = 0
+			AND p.chat_jid = messages.chat_jid AND p.message_id = messages.id AND p.kind = 'edit'
+			AND (p.sender = messages.sender OR (p.is_from_me AND messages.is_from_me))`,
+		chatJID, id)
+	if err != nil {
+		return fmt.Errorf("failed to apply message edit: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		DELETE FROM pending_changes
+		WHERE chat_jid = ?1 AND message_id = ?2
+			AND EXISTS (SELECT 1 FROM messages WHERE chat_jid = ?1 AND id = ?2)`,
+		chatJID, id)
+	if err != nil {
+		return fmt.Errorf("failed to discard applied message changes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package whatsapp
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func testImageMessage(id, caption string, ts time.Time) Message {
+	return Message{
+		ID: id, ChatJID: testChat, Sender: testSender, Text: caption, Timestamp: ts, MediaType: "image",
+		media: &waProto.Message{ImageMessage: &waProto.ImageMessage{Caption: proto.String(caption)}},
+	}
+}
+
+func TestSaveMessageKeepsEdits(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	ts := time.Unix(1700000000, 0)
+
+	original := Message{ID: "A", ChatJID: testChat, Sender: testSender, Text: "helo", Timestamp: ts}
+	if err := s.saveMessage(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.editMessage(ctx, testChat, "A", "hello", ts.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// A history sync or re-delivery brings the pre-edit copy again
+	if err := s.saveMessage(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := mustGetMessage(t, s, testChat, "A")
+	if msg.Text != "hello" || msg.EditedAt == nil {
+		t.Errorf("got text %q, edited at %v; want the edit to stick", msg.Text, msg.EditedAt)
+	}
+}
+
+func TestSaveMessageKeepsRevocations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	original := testImageMessage("A", "secret", time.Unix(1700000000, 0))
+
+	if err := s.saveMessage(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.revokeMessage(ctx, testChat, "A"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.saveMessage(ctx, original); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := mustGetMessage(t, s, testChat, "A")
+	if !msg.Revoked || msg.Text != "" || msg.MediaType != "" {
+		t.Errorf("got revoked=%v text=%q media type=%q; want the deletion to stick", msg.Revoked, msg.Text, msg.MediaType)
+	}
+	if media, err := s.messageMedia(ctx, testChat, "A"); err != nil || media != nil {
+		t.Errorf("messageMedia = %v, %v; want no media", media, err)
+	}
+}
+
+func TestPendingChanges(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	tests := []struct {
+		name        string
+		change      pendingChange
+		admins      []string
+		wantText    string
+		wantEdited  bool
+		wantRevoked bool
+	}{
+		{
+			name:       "edit by sender",
+			change:     pendingChange{Kind: changeEdit, Sender: testSender, Text: "edited"},
+			wantText:   "edited",
+			wantEdited: true,
+		},
+		{
+			name:     "edit by someone else",
+			change:   pendingChange{Kind: changeEdit, Sender: testOther, Text: "forged"},
+			wantText: "secret",
+		},
+		{
+			name:        "revoke by sender",
+			change:      pendingChange{Kind: changeRevoke, Sender: testSender},
+			wantRevoked: true,
+		},
+		{
+			name:        "revoke by admin",
+			change:      pendingChange{Kind: changeRevoke, Sender: testOther},
+			admins:      []string{testOther},
+			wantRevoked: true,
+		},
+		{
+			name:     "revoke by non-admin",
+			change:   pendingChange{Kind: changeRevoke, Sender: testOther},
+			admins:   []string{testSender},
+			wantText: "secret",
+		},
+		{
+			name:     "revoke with unknown admins",
+			change:   pendingChange{Kind: changeRevoke, Sender: testOther},
+			wantText: "secret",
+		},
+		{
+			name:     "edit by admin",
+			change:   pendingChange{Kind: changeEdit, Sender: testOther, Text: "forged"},
+			admins:   []string{testOther},
+			wantText: "secret",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, early := range []bool{true, false} {
+			name := tt.name
+			if early {
+				name += " before original"
+			}
+			t.Run(name, func(t *testing.T) {
+				ctx := context.Background()
+				s := newTestStore(t)
+				original := testImageMessage("A", "secret", ts)
+				change := tt.change
+				change.ChatJID, change.MessageID, change.Timestamp = testChat, "A", ts.Add(time.Minute)
+				if tt.admins != nil {
+					if err := s.setGroupAdmins(ctx, testChat, tt.admins, ts); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				if !early {
+					if err := s.saveMessage(ctx, original); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err := s.recordChange(ctx, change); err != nil {
+					t.Fatal(err)
+				}
+				if early {
+					if err := s.saveMessage(ctx, original); err != nil {
+						t.Fatal(err)
+					}
+				}
+
+				msg := mustGetMessage(t, s, testChat, "A")
+				if msg.Text != tt.wantText || (msg.EditedAt != nil) != tt.wantEdited || msg.Revoked != tt.wantRevoked {
+					t.Errorf("got text=%q edited=%v revoked=%v; want text=%q edited=%v revoked=%v",
+						msg.Text, msg.EditedAt != nil, msg.Revoked, tt.wantText, tt.wantEdited, tt.wantRevoked)
+				}
+
+				var pending int
+				if err := s.db.QueryRow("SELECT COUNT(*) FROM pending_changes").Scan(&pending); err != nil {
+					t.Fatal(err)
+				}
+				if pending != 0 {
+					t.Errorf("%d changes still pending after the original was stored", pending)
+				}
+			})
+		}
+	}
+}
+
+func TestPendingChangesExpire(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	now := time.Now()
+
+	// Changes whose message hasn't arrived within revokeWindow never will
+	for id, ts := range map[string]time.Time{"OLD": now.Add(-revokeWindow - time.Hour), "NEW": now.Add(-time.Hour)} {
+		err := s.recordChange(ctx, pendingChange{ChatJID: testChat, MessageID: id, Kind: changeRevoke, Sender: testSender, Timestamp: ts})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.recordChange(ctx, pendingChange{ChatJID: testChat, MessageID: "LATEST", Kind: changeEdit, Sender: testSender, Timestamp: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.db.Query("SELECT message_id FROM pending_changes ORDER BY timestamp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var pending []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		pending = append(pending, id)
+	}
+	if want := []string{"NEW", "LATEST"}; !slices.Equal(pending, want) {
+		t.Errorf("pending changes %v, want %v", pending, want)
+	}
+}
@@ -0,0 +1,65 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// setGroupAdmins replaces the cached admins of a group. Admins are listed under
+// each JID, LID or phone number, that their messages may be attributed to.
+func (s *messageStore) setGroupAdmins(ctx context.Context, groupJID string, admins []string, syncedAt time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM group_admins WHERE group_jid = ?", groupJID); err != nil {
+		return fmt.Errorf("failed to clear group admins: %w", err)
+	}
+	for _, jid := range admins {
+		_, err := tx.ExecContext(ctx, "INSERT OR IGNORE INTO group_admins (group_jid, jid, synced_at) VALUES (?, ?, ?)",
+			groupJID, jid, syncedAt.Unix())
+		if err != nil {
+			return fmt.Errorf("failed to save group admin: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// groupAdminsSynced returns when the admins of a group were cached, or the zero
+// time if they aren't
+func (s *messageStore) groupAdminsSynced(ctx context.Context, groupJID string) (time.Time, error) {
+	var syncedAt int64
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(synced_at), 0) FROM group_admins WHERE group_jid = ?", groupJID).
+		Scan(&syncedAt)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to query group admins: %w", err)
+	}
+	if syncedAt == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(syncedAt, 0), nil
+}
+
+// isGroupAdmin reports whether jid is among the cached admins of a group
+func (s *messageStore) isGroupAdmin(ctx context.Context, groupJID, jid string) (bool, error) {
+	var admin bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM group_admins WHERE group_jid = ? AND jid = ?)",
+		groupJID, jid).Scan(&admin)
+	if err != nil {
+		return false, fmt.Errorf("failed to query group admins: %w", err)
+	}
+	return admin, nil
+}
+
+// forgetGroupAdmins drops the cached admins of a group, so they are fetched
+// again the next time they are needed
+func (s *messageStore) forgetGroupAdmins(ctx context.Context, groupJID string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM group_admins WHERE group_jid = ?", groupJID); err != nil {
+		return fmt.Errorf("failed to clear group admins: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package whatsapp
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGroupAdmins(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	synced := time.Unix(1700000000, 0)
+	const otherGroup = "120363111111111111@g.us"
+
+	checkAdmins := func(group string, want map[string]bool) {
+		t.Helper()
+		for jid, wantAdmin := range want {
+			admin, err := s.isGroupAdmin(ctx, group, jid)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if admin != wantAdmin {
+				t.Errorf("isGroupAdmin(%s, %s) = %v, want %v", group, jid, admin, wantAdmin)
+			}
+		}
+	}
+
+	if got, err := s.groupAdminsSynced(ctx, testChat); err != nil || !got.IsZero() {
+		t.Fatalf("groupAdminsSynced before caching = %v, %v; want the zero time", got, err)
+	}
+
+	// Admins are listed under both of their JIDs, and only count in their own group
+	if err := s.setGroupAdmins(ctx, testChat, []string{testSender, "123456789@lid", testSender}, synced); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setGroupAdmins(ctx, otherGroup, []string{testOther}, synced); err != nil {
+		t.Fatal(err)
+	}
+	checkAdmins(testChat, map[string]bool{testSender: true, "123456789@lid": true, testOther: false})
+	if got, err := s.groupAdminsSynced(ctx, testChat); err != nil || !got.Equal(synced) {
+		t.Errorf("groupAdminsSynced = %v, %v; want %v", got, err, synced)
+	}
+
+	// Fetching the admins again replaces them
+	if err := s.setGroupAdmins(ctx, testChat, []string{testOther}, synced.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	checkAdmins(testChat, map[string]bool{testSender: false, "123456789@lid": false, testOther: true})
+
+	if err := s.forgetGroupAdmins(ctx, testChat); err != nil {
+		t.Fatal(err)
+	}
+	checkAdmins(testChat, map[string]bool{testOther: false})
+	checkAdmins(otherGroup, map[string]bool{testOther: true})
+	if got, err := s.groupAdminsSynced(ctx, testChat); err != nil || !got.IsZero() {
+		t.Errorf("groupAdminsSynced after forgetting = %v, %v; want the zero time", got, err)
+	}
+}
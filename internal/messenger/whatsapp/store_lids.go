@@ -0,0 +1,94 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+)
+
+// storedLIDs returns the distinct LIDs messages, chats, reactions, receipts,
+// presence and pending message changes are stored under
+func (s *messageStore) storedLIDs(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT chat_jid FROM messages WHERE chat_jid LIKE '%@lid'
+		UNION SELECT sender FROM messages WHERE sender LIKE '%@lid'
+		UNION SELECT reply_to_sender FROM messages WHERE reply_to_sender LIKE '%@lid'
+		UNION SELECT jid FROM chats WHERE jid LIKE '%@lid'
+		UNION SELECT sender FROM reactions WHERE sender LIKE '%@lid'
+		UNION SELECT recipient FROM receipts WHERE recipient LIKE '%@lid'
+		UNION SELECT jid FROM presence WHERE jid LIKE '%@lid'
+		UNION SELECT chat_jid FROM pending_changes WHERE chat_jid LIKE '%@lid'
+		UNION SELECT sender FROM pending_changes WHERE sender LIKE '%@lid'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stored LIDs: %w", err)
+	}
+	defer rows.Close()
+
+	var lids []string
+	for rows.Next() {
+		var lid string
+		if err := rows.Scan(&lid); err != nil {
+			return nil, fmt.Errorf("failed to scan stored LID: %w", err)
+		}
+		lids = append(lids, lid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stored LIDs: %w", err)
+	}
+	return lids, nil
+}
+
+// lidMerges move everything stored under a LID (?1) to its phone number JID (?2).
+// Rows that exist under both keep the phone number copy, except for chats and
+// presence, where the two are combined.
+var lidMerges = []string{
+	"UPDATE OR IGNORE messages SET chat_jid = ?2 WHERE chat_jid = ?1",
+	"DELETE FROM messages WHERE chat_jid = ?1",
+	// Message keys keep naming the sender by LID
+	"UPDATE messages SET sender_address = ?1 WHERE sender = ?1 AND sender_address = ''",
+	"UPDATE messages SET sender = ?2 WHERE sender = ?1",
+	"UPDATE messages SET reply_to_sender = ?2 WHERE reply_to_sender = ?1",
+	"UPDATE OR IGNORE reactions SET chat_jid = ?2 WHERE chat_jid = ?1",
+	"UPDATE OR IGNORE reactions SET sender = ?2 WHERE sender = ?1",
+	"DELETE FROM reactions WHERE chat_jid = ?1 OR sender = ?1",
+	"UPDATE OR IGNORE receipts SET chat_jid = ?2 WHERE chat_jid = ?1",
+	"UPDATE OR IGNORE receipts SET recipient = ?2 WHERE recipient = ?1",
+	"DELETE FROM receipts WHERE chat_jid = ?1 OR recipient = ?1",
+	"UPDATE OR IGNORE pending_changes SET chat_jid = ?2 WHERE chat_jid = ?1",
+	"DELETE FROM pending_changes WHERE chat_jid = ?1",
+	"UPDATE pending_changes SET sender = ?2 WHERE sender = ?1",
+	// Group admins are listed under both of their JIDs
+	"INSERT OR IGNORE INTO group_admins (group_jid, jid, synced_at) SELECT group_jid, ?2, synced_at FROM group_admins WHERE jid = ?1",
+	`INSERT INTO chats (jid, name, is_group, unread_count, last_message_time, archived, pinned, muted_until, state_updated_at)
+		SELECT ?2, name, is_group, unread_count, last_message_time, archived, pinned, muted_until, state_updated_at FROM chats WHERE jid = ?1
+		ON CONFLICT (jid) DO UPDATE SET
+			name = CASE WHEN chats.name <> '' THEN chats.name ELSE excluded.name END,
+			unread_count = chats.unread_count + excluded.unread_count,
+			last_message_time = MAX(chats.last_message_time, excluded.last_message_time),
+			state_updated_at = MAX(chats.state_updated_at, excluded.state_updated_at)`,
+	"DELETE FROM chats WHERE jid = ?1",
+	`INSERT INTO presence (jid, online, last_seen, updated_at, subscribed)
+		SELECT ?2, online, last_seen, updated_at, subscribed FROM presence WHERE jid = ?1
+		ON CONFLICT (jid) DO UPDATE SET
+			online = CASE WHEN excluded.updated_at > presence.updated_at THEN excluded.online ELSE presence.online END,
+			last_seen = MAX(presence.last_seen, excluded.last_seen),
+			updated_at = MAX(presence.updated_at, excluded.updated_at),
+			subscribed = MAX(presence.subscribed, excluded.subscribed)`,
+	"DELETE FROM presence WHERE jid = ?1",
+}
+
+// mergeJID moves everything stored under lid to the phone number JID pn in one transaction
+func (s *messageStore) mergeJID(ctx context.Context, lid, pn string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range lidMerges {
+		if _, err := tx.ExecContext(ctx, query, lid, pn); err != nil {
+			return fmt.Errorf("failed to merge %s into %s: %w", lid, pn, err)
+		}
+	}
+
+	return tx.Commit()
+}
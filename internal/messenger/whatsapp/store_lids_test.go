@@ -0,0 +1,103 @@
+package whatsapp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestMergeJID(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	const lid = "98765432101234@lid"
+	pn := testSender
+	ts := time.Unix(1700000000, 0)
+
+	mustDo := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The direct chat was stored under both JIDs, with message B in both copies
+	for _, msg := range []Message{
+		{ID: "A", ChatJID: lid, Sender: lid, Text: "under lid", Timestamp: ts},
+		{ID: "B", ChatJID: lid, Sender: lid, Text: "lid copy", Timestamp: ts.Add(time.Second)},
+		{ID: "B", ChatJID: pn, Sender: pn, Text: "phone copy", Timestamp: ts.Add(time.Second)},
+		{ID: "C", ChatJID: pn, Sender: pn, Text: "under phone", Timestamp: ts.Add(2 * time.Second)},
+		// In a group the LID is the sender and is quoted
+		{ID: "G1", ChatJID: testChat, Sender: lid, Text: "group", Timestamp: ts},
+		{ID: "G2", ChatJID: testChat, Sender: testOther, Text: "reply", Timestamp: ts.Add(time.Second),
+			ReplyTo: &QuotedMessage{ID: "G1", Sender: lid, Text: "group"}},
+	} {
+		mustDo(s.receiveMessage(ctx, msg))
+	}
+	mustDo(s.setChatName(ctx, lid, "Maria"))
+
+	for _, r := range []messageReaction{
+		{ChatJID: lid, MessageID: "A", Sender: lid, Emoji: "👍", Timestamp: ts},
+		{ChatJID: lid, MessageID: "B", Sender: lid, Emoji: "😂", Timestamp: ts},
+		{ChatJID: pn, MessageID: "B", Sender: pn, Emoji: "❤️", Timestamp: ts},
+		{ChatJID: testChat, MessageID: "G2", Sender: lid, Emoji: "🙏", Timestamp: ts},
+	} {
+		mustDo(s.saveReaction(ctx, r))
+	}
+
+	mustDo(s.saveReceipt(ctx, testChat, "G0", lid, types.ReceiptTypeDelivered, ts))
+	mustDo(s.saveReceipt(ctx, testChat, "G0", pn, types.ReceiptTypeRead, ts.Add(time.Second)))
+	mustDo(s.saveReceipt(ctx, testChat, "G3", lid, types.ReceiptTypeRead, ts))
+	mustDo(s.saveReceipt(ctx, lid, "D0", lid, types.ReceiptTypeDelivered, ts))
+
+	mustDo(s.savePresence(ctx, lid, true, time.Time{}, ts.Add(time.Minute)))
+	mustDo(s.savePresence(ctx, pn, false, ts, ts))
+	mustDo(s.recordChange(ctx, pendingChange{ChatJID: lid, MessageID: "E", Kind: changeEdit, Sender: lid, Text: "edited", Timestamp: ts}))
+
+	mustDo(s.mergeJID(ctx, lid, pn))
+
+	lids, err := s.storedLIDs(ctx)
+	mustDo(err)
+	if len(lids) != 0 {
+		t.Errorf("LIDs still stored after merging: %v", lids)
+	}
+
+	count := func(query string, args ...interface{}) int {
+		t.Helper()
+		var n int
+		mustDo(s.db.QueryRowContext(ctx, query, args...).Scan(&n))
+		return n
+	}
+	for _, check := range []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"direct messages", "SELECT COUNT(*) FROM messages WHERE chat_jid = ?1", 3},
+		{"messages sent by the phone number", "SELECT COUNT(*) FROM messages WHERE sender = ?1", 4},
+		{"phone copy of the duplicate", "SELECT COUNT(*) FROM messages WHERE chat_jid = ?1 AND id = 'B' AND text = 'phone copy'", 1},
+		{"group message keys naming the LID", "SELECT COUNT(*) FROM messages WHERE id = 'G1' AND sender_address = ?2", 1},
+		{"replies quoting the phone number", "SELECT COUNT(*) FROM messages WHERE reply_to_sender = ?1", 1},
+		{"direct chat reactions", "SELECT COUNT(*) FROM reactions WHERE chat_jid = ?1", 2},
+		{"reactions by the phone number", "SELECT COUNT(*) FROM reactions WHERE sender = ?1", 3},
+		{"phone copy of the duplicate reaction", "SELECT COUNT(*) FROM reactions WHERE message_id = 'B' AND emoji = '❤️'", 1},
+		{"receipts of the phone number", "SELECT COUNT(*) FROM receipts WHERE recipient = ?1", 3},
+		{"phone copy of the duplicate receipt", "SELECT COUNT(*) FROM receipts WHERE message_id = 'G0' AND read_at > 0 AND delivered_at = 0", 1},
+		{"direct chat receipts", "SELECT COUNT(*) FROM receipts WHERE chat_jid = ?1", 1},
+		{"chats", "SELECT COUNT(*) FROM chats", 2},
+		{"merged chat", "SELECT COUNT(*) FROM chats WHERE jid = ?1 AND name = 'Maria' AND last_message_time = ?3", 1},
+		{"merged presence", "SELECT COUNT(*) FROM presence WHERE jid = ?1 AND online = 1 AND last_seen = ?4", 1},
+		{"pending changes", "SELECT COUNT(*) FROM pending_changes WHERE chat_jid = ?1 AND sender = ?1", 1},
+	} {
+		if got := count(check.query, pn, lid, ts.Add(2*time.Second).Unix(), ts.Unix()); got != check.want {
+			t.Errorf("%s: got %d, want %d", check.name, got, check.want)
+		}
+	}
+
+	// Merging again changes nothing
+	mustDo(s.mergeJID(ctx, lid, pn))
+	if got := count("SELECT COUNT(*) FROM messages"); got != 5 {
+		t.Errorf("got %d messages after merging twice, want 5", got)
+	}
+}
@@ -0,0 +1,80 @@
+package whatsapp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// savePresence records a presence update for a contact. A zero lastSeen keeps
+// the last seen time we already know.
+func (s *messageStore) savePresence(ctx context.Context, jid string, online bool, lastSeen, updatedAt time.Time) error {
+	var lastSeenUnix int64
+	if !lastSeen.IsZero() {
+		lastSeenUnix = lastSeen.Unix()
+	}
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO presence (jid, online, last_seen, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (jid) DO UPDATE SET
+			online = excluded.online,
+			last_seen = MAX(presence.last_seen, excluded.last_seen),
+			updated_at = excluded.updated_at`,
+		jid, online, lastSeenUnix, updatedAt.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save presence: %w", err)
+	}
+	return nil
+}
+
+// setPresenceSubscribed remembers that we follow a contact's presence, so the
+// subscription can be renewed after reconnecting
+func (s *messageStore) setPresenceSubscribed(ctx context.Context, jid string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO presence (jid, subscribed) VALUES (?, 1)
+		ON CONFLICT (jid) DO UPDATE SET subscribed = 1`, jid)
+	if err != nil {
+		return fmt.Errorf("failed to save presence subscription: %w", err)
+	}
+	return nil
+}
+
+// getPresence returns what we know about a contact's presence, or nil if nothing
+func (s *messageStore) getPresence(ctx context.Context, jid string) (*ContactPresence, error) {
+	presence := ContactPresence{JID: jid}
+	var lastSeen, updatedAt int64
+	err := s.db.QueryRowContext(ctx, "SELECT online, last_seen, updated_at, subscribed FROM presence WHERE jid = ?", jid).
+		Scan(&presence.Online, &lastSeen, &updatedAt, &presence.Subscribed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to query presence: %w", err)
+	}
+
+	presence.LastSeen = optionalTime(lastSeen)
+	presence.UpdatedAt = optionalTime(updatedAt)
+	return &presence, nil
+}
+
+// presenceSubscriptions returns the contacts whose presence we follow
+func (s *messageStore) presenceSubscriptions(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT jid FROM presence WHERE subscribed = 1")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query presence subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var jids []string
+	for rows.Next() {
+		var jid string
+		if err := rows.Scan(&jid); err != nil {
+			return nil, fmt.Errorf("failed to scan presence subscription: %w", err)
+		}
+		jids = append(jids, jid)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read presence subscriptions: %w", err)
+	}
+	return jids, nil
+}
@@ -0,0 +1,97 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// messageReaction is a single sender's reaction to a message. An empty emoji
+// means the sender removed their reaction.
+type messageReaction struct {
+	ChatJID   string
+	MessageID string
+	Sender    string
+	Emoji     string
+	Timestamp time.Time
+}
+
+// saveReaction records a reaction. Removed reactions are kept with an empty emoji
+// so an older copy arriving later, e.g. from history sync, can't bring them back.
+func saveReaction(ctx context.Context, db execer, r messageReaction) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO reactions (chat_jid, message_id, sender, emoji, timestamp)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (chat_jid, message_id, sender) DO UPDATE SET
+			emoji = excluded.emoji,
+			timestamp = excluded.timestamp
+		WHERE excluded.timestamp >= reactions.timestamp`,
+		r.ChatJID, r.MessageID, r.Sender, r.Emoji, r.Timestamp.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save reaction: %w", err)
+	}
+	return nil
+}
+
+// saveReaction records a single reaction
+func (s *messageStore) saveReaction(ctx context.Context, r messageReaction) error {
+	return saveReaction(ctx, s.db, r)
+}
+
+// attachReactions fills in the aggregated reactions of each message
+func (s *messageStore) attachReactions(ctx context.Context, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	index := make(map[[2]string]int, len(messages))
+	args := make([]interface{}, 0, 2*len(messages))
+	for i, msg := range messages {
+		index[[2]string{msg.ChatJID, msg.ID}] = i
+		args = append(args, msg.ChatJID, msg.ID)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT chat_jid, message_id, emoji, sender FROM reactions
+		WHERE emoji <> '' AND (chat_jid, message_id) IN (VALUES `+strings.TrimSuffix(strings.Repeat("(?, ?), ", len(messages)), ", ")+`)
+		ORDER BY timestamp`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query reactions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var chatJID, messageID, emoji, sender string
+		if err := rows.Scan(&chatJID, &messageID, &emoji, &sender); err != nil {
+			return fmt.Errorf("failed to scan reaction: %w", err)
+		}
+
+		msg := &messages[index[[2]string{chatJID, messageID}]]
+		found := false
+		for i := range msg.Reactions {
+			if msg.Reactions[i].Emoji == emoji {
+				msg.Reactions[i].Count++
+				msg.Reactions[i].Senders = append(msg.Reactions[i].Senders, sender)
+				found = true
+				break
+			}
+		}
+		if !found {
+			msg.Reactions = append(msg.Reactions, Reaction{Emoji: emoji, Count: 1, Senders: []string{sender}})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read reactions: %w", err)
+	}
+
+	// Most used first; ties keep the order the emoji were first used in
+	for i := range messages {
+		sort.SliceStable(messages[i].Reactions, func(a, b int) bool {
+			return messages[i].Reactions[a].Count > messages[i].Reactions[b].Count
+		})
+	}
+
+	return nil
+}
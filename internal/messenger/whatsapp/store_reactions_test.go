@@ -0,0 +1,64 @@
+package whatsapp
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReactions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	ts := time.Unix(1700000000, 0)
+	const (
+		alice = "15550000001@s.whatsapp.net"
+		bob   = "15550000002@s.whatsapp.net"
+		carol = "15550000003@s.whatsapp.net"
+		dave  = "15550000004@s.whatsapp.net"
+	)
+
+	for i, r := range []messageReaction{
+		// Alice changes her mind, replacing her reaction
+		{MessageID: "A", Sender: alice, Emoji: "👍"},
+		{MessageID: "A", Sender: bob, Emoji: "❤️"},
+		{MessageID: "A", Sender: carol, Emoji: "❤️"},
+		{MessageID: "A", Sender: alice, Emoji: "❤️"},
+		// Bob takes his back, and an older copy arriving later doesn't restore it
+		{MessageID: "A", Sender: bob, Emoji: ""},
+		{MessageID: "A", Sender: bob, Emoji: "❤️", Timestamp: ts},
+		// The most used emoji comes first, and ties keep the order they were first used in
+		{MessageID: "B", Sender: alice, Emoji: "😂"},
+		{MessageID: "B", Sender: bob, Emoji: "🙏"},
+		{MessageID: "B", Sender: carol, Emoji: "👍"},
+		{MessageID: "B", Sender: dave, Emoji: "👍"},
+	} {
+		r.ChatJID = testChat
+		if r.Timestamp.IsZero() {
+			r.Timestamp = ts.Add(time.Duration(i+1) * time.Second)
+		}
+		if err := s.saveReaction(ctx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	messages := []Message{{ID: "A", ChatJID: testChat}, {ID: "B", ChatJID: testChat}, {ID: "C", ChatJID: testChat}}
+	if err := s.attachReactions(ctx, messages); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]Reaction{
+		"A": {{Emoji: "❤️", Count: 2, Senders: []string{carol, alice}}},
+		"B": {
+			{Emoji: "👍", Count: 2, Senders: []string{carol, dave}},
+			{Emoji: "😂", Count: 1, Senders: []string{alice}},
+			{Emoji: "🙏", Count: 1, Senders: []string{bob}},
+		},
+		"C": nil,
+	}
+	for _, msg := range messages {
+		if !reflect.DeepEqual(msg.Reactions, want[msg.ID]) {
+			t.Errorf("reactions to %s = %+v, want %+v", msg.ID, msg.Reactions, want[msg.ID])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Message states in the order they are reached. A message with no receipt from
+// a recipient yet is only known to have been sent.
+const (
+	statusSent      = "sent"
+	statusDelivered = "delivered"
+	statusRead      = "read"
+	statusPlayed    = "played"
+)
+
+// statusRank orders the message states
+var statusRank = map[string]int{statusSent: 0, statusDelivered: 1, statusRead: 2, statusPlayed: 3}
+
+// receiptColumns maps the receipt types we track to the column recording them
+var receiptColumns = map[types.ReceiptType]string{
+	types.ReceiptTypeDelivered: "delivered_at",
+	types.ReceiptTypeRead:      "read_at",
+	types.ReceiptTypePlayed:    "played_at",
+}
+
+// saveReceipt records that recipient has received, read or played messageID.
+// Only the first receipt of each type is kept, and other receipt types are ignored.
+func (s *messageStore) saveReceipt(ctx context.Context, chatJID, messageID, recipient string, receiptType types.ReceiptType, ts time.Time) error {
+	column, ok := receiptColumns[receiptType]
+	if !ok {
+		return nil
+	}
+
+	_, err := s.db.ExecContext(ctx, fmt.Sprintf(`
+		INSERT INTO receipts (chat_jid, message_id, recipient, %[1]s) VALUES (?, ?, ?, ?)
+		ON CONFLICT (chat_jid, message_id, recipient) DO UPDATE SET %[1]s = excluded.%[1]s
+		WHERE receipts.%[1]s = 0`, column),
+		chatJID, messageID, recipient, ts.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to save receipt: %w", err)
+	}
+	return nil
+}
+
+// messageReceipts returns the receipts recorded for a message, one per recipient
+func (s *messageStore) messageReceipts(ctx context.Context, chatJID, messageID string) ([]RecipientStatus, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT recipient, delivered_at, read_at, played_at FROM receipts
+		WHERE chat_jid = ? AND message_id = ?
+		ORDER BY recipient`, chatJID, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query receipts: %w", err)
+	}
+	defer rows.Close()
+
+	recipients := []RecipientStatus{}
+	for rows.Next() {
+		var r RecipientStatus
+		var delivered, read, played int64
+		if err := rows.Scan(&r.JID, &delivered, &read, &played); err != nil {
+			return nil, fmt.Errorf("failed to scan receipt: %w", err)
+		}
+		r.DeliveredAt, r.ReadAt, r.PlayedAt = optionalTime(delivered), optionalTime(read), optionalTime(played)
+
+		// Reading a message implies it was delivered, even if that receipt never came
+		switch {
+		case r.PlayedAt != nil:
+			r.Status = statusPlayed
+		case r.ReadAt != nil:
+			r.Status = statusRead
+		default:
+			r.Status = statusDelivered
+		}
+		recipients = append(recipients, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read receipts: %w", err)
+	}
+	return recipients, nil
+}
+
+// optionalTime converts a Unix timestamp column to a time, where 0 means unset
+func optionalTime(ts int64) *time.Time {
+	if ts == 0 {
+		return nil
+	}
+	t := time.Unix(ts, 0).UTC()
+	return &t
+}
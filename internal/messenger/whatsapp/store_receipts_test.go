@@ -0,0 +1,73 @@
+package whatsapp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestMessageReceipts(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	ts := time.Unix(1700000000, 0).UTC()
+	const (
+		alice = "15550000001@s.whatsapp.net"
+		bob   = "15550000002@s.whatsapp.net"
+		carol = "15550000003@s.whatsapp.net"
+		dave  = "15550000004@s.whatsapp.net"
+	)
+
+	for _, r := range []struct {
+		id, recipient string
+		receiptType   types.ReceiptType
+		at            time.Duration
+	}{
+		{"A", alice, types.ReceiptTypeDelivered, 1},
+		// Only the first receipt of each type counts
+		{"A", bob, types.ReceiptTypeDelivered, 1},
+		{"A", bob, types.ReceiptTypeRead, 2},
+		{"A", bob, types.ReceiptTypeRead, 5},
+		// A read receipt without a delivery receipt still means delivered
+		{"A", carol, types.ReceiptTypeRead, 3},
+		{"A", dave, types.ReceiptTypeDelivered, 1},
+		{"A", dave, types.ReceiptTypeRead, 2},
+		{"A", dave, types.ReceiptTypePlayed, 4},
+		// Receipts we don't track are ignored
+		{"A", alice, types.ReceiptTypeRetry, 2},
+		{"B", alice, types.ReceiptTypeRead, 9},
+	} {
+		if err := s.saveReceipt(ctx, testChat, r.id, r.recipient, r.receiptType, ts.Add(r.at*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	receipts, err := s.messageReceipts(ctx, testChat, "A")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(d time.Duration) *time.Time {
+		t := ts.Add(d * time.Second)
+		return &t
+	}
+	want := []RecipientStatus{
+		{JID: alice, Status: statusDelivered, DeliveredAt: at(1)},
+		{JID: bob, Status: statusRead, DeliveredAt: at(1), ReadAt: at(2)},
+		{JID: carol, Status: statusRead, ReadAt: at(3)},
+		{JID: dave, Status: statusPlayed, DeliveredAt: at(1), ReadAt: at(2), PlayedAt: at(4)},
+	}
+	if len(receipts) != len(want) {
+		t.Fatalf("got %d receipts, want %d: %+v", len(receipts), len(want), receipts)
+	}
+	equalTime := func(a, b *time.Time) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+	}
+	for i, got := range receipts {
+		w := want[i]
+		if got.JID != w.JID || got.Status != w.Status || !equalTime(got.DeliveredAt, w.DeliveredAt) ||
+			!equalTime(got.ReadAt, w.ReadAt) || !equalTime(got.PlayedAt, w.PlayedAt) {
+			t.Errorf("receipt %d = %+v, want %+v", i, got, w)
+		}
+	}
+}
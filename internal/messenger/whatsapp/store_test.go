@@ -0,0 +1,291 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testChat   = "120363000000000000@g.us"
+	testSender = "15551234567@s.whatsapp.net"
+	testOther  = "15557654321@s.whatsapp.net"
+)
+
+// newTestStore opens an empty message store in a temporary directory
+func newTestStore(t *testing.T) *messageStore {
+	t.Helper()
+	s, err := newMessageStore(filepath.Join(t.TempDir(), "messages.db"))
+	if err != nil {
+		t.Fatalf("newMessageStore: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// mustGetMessage returns a stored message, failing the test if it isn't stored
+func mustGetMessage(t *testing.T, s *messageStore, chatJID, id string) *Message {
+	t.Helper()
+	msg, err := s.getMessage(context.Background(), chatJID, id)
+	if err != nil {
+		t.Fatalf("getMessage(%s, %s): %v", chatJID, id, err)
+	}
+	if msg == nil {
+		t.Fatalf("getMessage(%s, %s): not stored", chatJID, id)
+	}
+	return msg
+}
+
+// walkPages fetches the page for token and then follows the cursor each page
+// returns until there is none, returning every page in the order fetched
+func walkPages[P any](t *testing.T, fetch func(token string) (*P, error), cursor func(*P) string, token string) []*P {
+	t.Helper()
+	var pages []*P
+	for range 100 {
+		page, err := fetch(token)
+		if err != nil {
+			t.Fatalf("fetching page %d: %v", len(pages)+1, err)
+		}
+		pages = append(pages, page)
+		if token = cursor(page); token == "" {
+			return pages
+		}
+	}
+	t.Fatal("paging did not end")
+	return nil
+}
+
+// checkPaging walks a listing of want, newest first, to its end with the next
+// cursors and then back to its start with the previous cursors of the last
+// page, checking for gaps and duplicates both ways
+func checkPaging[P any](t *testing.T, fetch func(token string) (*P, error), keys func(*P) []string,
+	next, prev func(*P) string, want []string, pageSize int) {
+	t.Helper()
+
+	forward := walkPages(t, fetch, next, "")
+	var got []string
+	for _, page := range forward {
+		got = append(got, keys(page)...)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("paging to older entries got %v, want %v", got, want)
+	}
+	if wantPages := (len(want) + pageSize - 1) / pageSize; len(forward) != wantPages {
+		t.Errorf("got %d pages, want %d", len(forward), wantPages)
+	}
+
+	last := forward[len(forward)-1]
+	backward := walkPages(t, fetch, prev, prev(last))
+	got = keys(last)
+	for _, page := range backward {
+		got = append(keys(page), got...)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("paging back to newer entries got %v, want %v", got, want)
+	}
+}
+
+func TestListMessagesPaging(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	base := time.Unix(1700000000, 0)
+
+	// Several messages share each timestamp, and another chat's messages are interleaved
+	var newestFirst []string
+	for i := range 23 {
+		id := fmt.Sprintf("M%02d", i)
+		ts := base.Add(time.Duration(i/4) * time.Second)
+		for _, msg := range []Message{
+			{ID: id, ChatJID: testChat, Sender: testSender, Text: id, Timestamp: ts},
+			{ID: "X" + id, ChatJID: testOther, Sender: testOther, Text: id, Timestamp: ts},
+		} {
+			if err := s.saveMessage(ctx, msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		newestFirst = append([]string{id}, newestFirst...)
+	}
+
+	fetch := func(token string) (*MessagePage, error) {
+		return s.listMessages(ctx, MessageFilter{ChatJID: testChat, Limit: 5, Cursor: token})
+	}
+	ids := func(p *MessagePage) []string {
+		var ids []string
+		for _, msg := range p.Messages {
+			ids = append(ids, msg.ID)
+		}
+		return ids
+	}
+	checkPaging(t, fetch, ids,
+		func(p *MessagePage) string { return p.NextCursor },
+		func(p *MessagePage) string { return p.PrevCursor },
+		newestFirst, 5)
+
+	// Asking for messages newer than the newest hands the cursor back for polling
+	latest := pageCursor{Timestamp: base.Add(time.Minute).Unix(), Newer: true}.encode()
+	page, err := fetch(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 0 || page.PrevCursor != latest || page.NextCursor != "" {
+		t.Errorf("polling for newer messages got %v, prev %q, next %q; want nothing and the same cursor",
+			ids(page), page.PrevCursor, page.NextCursor)
+	}
+}
+
+func TestListChatsPaging(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	base := time.Unix(1700000000, 0)
+
+	// Chats sharing a timestamp are ordered by JID
+	type entry struct {
+		jid string
+		ts  time.Time
+	}
+	var chats []entry
+	for i := range 13 {
+		// Give chats of the same timestamp JIDs out of insertion order
+		chat := entry{fmt.Sprintf("155500%05d@s.whatsapp.net", (i*7)%13), base.Add(time.Duration(i/3) * time.Second)}
+		if err := s.saveConversation(ctx, Chat{JID: chat.jid, LastActivity: chat.ts}, nil, nil); err != nil {
+			t.Fatal(err)
+		}
+		chats = append(chats, chat)
+	}
+	slices.SortFunc(chats, func(a, b entry) int {
+		if c := b.ts.Compare(a.ts); c != 0 {
+			return c
+		}
+		return strings.Compare(a.jid, b.jid)
+	})
+	var newestFirst []string
+	for _, chat := range chats {
+		newestFirst = append(newestFirst, chat.jid)
+	}
+
+	fetch := func(token string) (*ChatPage, error) {
+		return s.listChats(ctx, 4, token, false)
+	}
+	jids := func(p *ChatPage) []string {
+		var jids []string
+		for _, chat := range p.Chats {
+			jids = append(jids, chat.JID)
+		}
+		return jids
+	}
+	checkPaging(t, fetch, jids,
+		func(p *ChatPage) string { return p.NextCursor },
+		func(p *ChatPage) string { return p.PrevCursor },
+		newestFirst, 4)
+}
+
+func TestSaveConversationKeepsLiveState(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	synced := time.Now().Add(-time.Hour).Truncate(time.Second)
+	snapshot := Chat{JID: testSender, UnreadCount: 3, Archived: true, Pinned: true, Muted: true, LastActivity: synced}
+
+	check := func(step string, want Chat) {
+		t.Helper()
+		chat, err := s.getChat(ctx, testSender)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chat.UnreadCount != want.UnreadCount || chat.Archived != want.Archived || chat.Pinned != want.Pinned || chat.Muted != want.Muted {
+			t.Errorf("%s: got unread=%d archived=%v pinned=%v muted=%v; want unread=%d archived=%v pinned=%v muted=%v", step,
+				chat.UnreadCount, chat.Archived, chat.Pinned, chat.Muted, want.UnreadCount, want.Archived, want.Pinned, want.Muted)
+		}
+	}
+
+	// A chat only known by name takes its state from history sync
+	if err := s.setChatName(ctx, testSender, "Alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.saveConversation(ctx, snapshot, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	check("first sync", snapshot)
+
+	// Changes seen live survive the same state arriving again in a later chunk
+	if err := s.markChatRead(ctx, testSender, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setChatArchived(ctx, testSender, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setChatPinned(ctx, testSender, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.setChatMuted(ctx, testSender, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.saveConversation(ctx, snapshot, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	check("sync after live changes", Chat{})
+
+	// State from after the live changes is taken
+	newer := snapshot
+	newer.UnreadCount, newer.Pinned, newer.LastActivity = 1, false, time.Now().Add(time.Hour)
+	if err := s.saveConversation(ctx, newer, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	check("newer sync", newer)
+}
+
+func TestReplyQuotes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	base := time.Unix(1700000000, 0).UTC()
+	quotedAt := base.Add(time.Second)
+
+	// The quote embedded in a reply is a copy made when it was sent
+	for _, msg := range []Message{
+		{ID: "Q", ChatJID: testChat, Sender: testOther, Text: "original", Timestamp: quotedAt},
+		{ID: "R1", ChatJID: testChat, Sender: testSender, Text: "stored", Timestamp: base.Add(2 * time.Second),
+			ReplyTo: &QuotedMessage{ID: "Q", Sender: testOther, Text: "copy"}},
+		{ID: "R2", ChatJID: testChat, Sender: testSender, Text: "not stored", Timestamp: base.Add(3 * time.Second),
+			ReplyTo: &QuotedMessage{ID: "GONE", Sender: testOther, Text: "copy of a message we don't have"}},
+		{ID: "R3", ChatJID: testChat, Sender: testSender, Text: "no reply", Timestamp: base.Add(4 * time.Second)},
+	} {
+		if err := s.saveMessage(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.editMessage(ctx, testChat, "Q", "edited", base.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]*QuotedMessage{
+		// The stored original is preferred, so edits to it show
+		"R1": {ID: "Q", Sender: testOther, Text: "edited", Timestamp: &quotedAt},
+		"R2": {ID: "GONE", Sender: testOther, Text: "copy of a message we don't have"},
+		"R3": nil,
+	}
+	check := func(source string, msg Message) {
+		t.Helper()
+		if got, want := msg.ReplyTo, want[msg.ID]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: reply of %s = %+v, want %+v", source, msg.ID, got, want)
+		}
+	}
+
+	page, err := s.listMessages(ctx, MessageFilter{ChatJID: testChat})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range page.Messages {
+		check("listMessages", msg)
+	}
+	for id := range want {
+		mc, err := s.messageContext(ctx, testChat, id, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check("messageContext", mc.Message)
+	}
+}
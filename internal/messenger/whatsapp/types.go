@@ -1,12 +1,44 @@
 package whatsapp
 
-import "time"
+import (
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+)
+
+// WhatsAppConfig holds configuration for the WhatsApp messenger
+type WhatsAppConfig struct {
+	// DeviceDB is the path of the whatsmeow session database
+	DeviceDB string `json:"device_db"`
+
+	// MessageDB is the path of the local message store
+	MessageDB string `json:"message_db"`
+
+	// MediaDir is where downloaded media is saved
+	MediaDir string `json:"media_dir"`
+
+	// UploadDir is the only directory tools may send local files from. Empty
+	// disables sending files by path.
+	UploadDir string `json:"upload_dir"`
+
+	// DefaultCountryCode is the calling code, such as 351, assumed for phone
+	// numbers given without one
+	DefaultCountryCode string `json:"default_country_code"`
+}
 
 // Contact represents a WhatsApp contact
 type Contact struct {
 	JID         string `json:"jid"`
 	PhoneNumber string `json:"phone_number"`
+	LID         string `json:"lid,omitempty"`
 	Name        string `json:"name"`
+
+	// MatchedField is the field the search query matched: full_name, first_name,
+	// business_name, push_name, phone_number or jid. Score ranks the results by
+	// match quality and how recently we talked to the contact.
+	MatchedField    string     `json:"matched_field,omitempty"`
+	Score           float64    `json:"score"`
+	LastInteraction *time.Time `json:"last_interaction,omitempty"`
 }
 
 // Message represents a WhatsApp chat message
@@ -18,14 +50,64 @@ type Message struct {
 	Timestamp time.Time `json:"timestamp"`
 	IsFromMe  bool      `json:"is_from_me"`
 	MediaType string    `json:"media_type,omitempty"`
+
+	// EditedAt is set once the sender has edited the message; Revoked once they deleted it for everyone
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+	Revoked  bool       `json:"revoked,omitempty"`
+
+	// ReplyTo is the message this one quotes, if it is a reply
+	ReplyTo *QuotedMessage `json:"reply_to,omitempty"`
+
+	// Reactions aggregates the emoji reactions on the message, most used first
+	Reactions []Reaction `json:"reactions,omitempty"`
+
+	// Snippet and Score are only set on full-text search results
+	Snippet string  `json:"snippet,omitempty"`
+	Score   float64 `json:"score,omitempty"`
+
+	// media holds the attachment of media messages so it can be downloaded later
+	media *waProto.Message
+
+	// senderAddress is the JID the sender used in the message key, which is
+	// their LID in groups that address members by LID
+	senderAddress string
+}
+
+// QuotedMessage is the message a reply refers to. Text and Timestamp come from
+// the stored original when available, otherwise from the copy embedded in the reply.
+type QuotedMessage struct {
+	ID        string     `json:"id"`
+	Sender    string     `json:"sender,omitempty"`
+	Text      string     `json:"text,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// Reaction is one emoji reacted to a message, with everyone who used it
+type Reaction struct {
+	Emoji   string   `json:"emoji"`
+	Count   int      `json:"count"`
+	Senders []string `json:"senders"`
+}
+
+// MessageContext is a message together with the messages around it, oldest first
+type MessageContext struct {
+	Before  []Message `json:"before"`
+	Message Message   `json:"message"`
+	After   []Message `json:"after"`
 }
 
 // Chat represents a WhatsApp conversation
 type Chat struct {
-	JID         string   `json:"jid"`
-	Name        string   `json:"name"`
-	IsGroup     bool     `json:"is_group"`
-	LastMessage *Message `json:"last_message,omitempty"`
+	JID          string     `json:"jid"`
+	Name         string     `json:"name"`
+	IsGroup      bool       `json:"is_group"`
+	UnreadCount  int        `json:"unread_count"`
+	Archived     bool       `json:"archived"`
+	Pinned       bool       `json:"pinned"`
+	Muted        bool       `json:"muted"`
+	MutedUntil   *time.Time `json:"muted_until,omitempty"` // nil while muted means muted forever
+	LastActivity time.Time  `json:"last_activity"`
+	LastMessage  *Message   `json:"last_message,omitempty"`
 }
 
 // MessageFilter contains criteria for filtering WhatsApp messages
@@ -36,5 +118,206 @@ type MessageFilter struct {
 	ChatJID   string
 	Query     string
 	Limit     int
-	Page      int
+	Cursor    string
+}
+
+// MessagePage is one page of messages. Pass NextCursor back to continue with
+// older messages (or lower-ranked search hits) and PrevCursor to go the other way.
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+}
+
+// ChatPage is one page of chats, most recently active first
+type ChatPage struct {
+	Chats      []Chat `json:"chats"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// HistorySyncStatus summarises the history sync chunks received for one sync type
+type HistorySyncStatus struct {
+	SyncType      string    `json:"sync_type"`
+	Chunks        int       `json:"chunks"`
+	LastChunk     int       `json:"last_chunk"`
+	Progress      int       `json:"progress"`
+	Conversations int       `json:"conversations"`
+	Messages      int       `json:"messages"`
+	PushNames     int       `json:"push_names"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// GroupInfo describes a WhatsApp group and its settings
+type GroupInfo struct {
+	JID                  string             `json:"jid"`
+	Name                 string             `json:"name"`
+	Topic                string             `json:"topic,omitempty"`
+	Created              time.Time          `json:"created"`
+	Owner                string             `json:"owner,omitempty"`
+	Announce             bool               `json:"announce"` // only admins can send messages
+	Locked               bool               `json:"locked"`   // only admins can edit group info
+	JoinApprovalRequired bool               `json:"join_approval_required"`
+	DisappearingTimer    uint32             `json:"disappearing_timer,omitempty"` // seconds
+	ParticipantCount     int                `json:"participant_count"`
+	Participants         []GroupParticipant `json:"participants,omitempty"`
+}
+
+// GroupParticipant is a member of a WhatsApp group
+type GroupParticipant struct {
+	JID          string `json:"jid"`
+	PhoneNumber  string `json:"phone_number,omitempty"`
+	LID          string `json:"lid,omitempty"`
+	Name         string `json:"name,omitempty"`
+	IsAdmin      bool   `json:"is_admin"`
+	IsSuperAdmin bool   `json:"is_super_admin"`
+}
+
+// GroupParticipantResult is the outcome of a group change for a single participant.
+// WhatsApp applies participant changes individually, so some may fail while others succeed.
+type GroupParticipantResult struct {
+	JID            string `json:"jid"`
+	Success        bool   `json:"success"`
+	ErrorCode      int    `json:"error_code,omitempty"`
+	Error          string `json:"error,omitempty"`
+	InviteRequired bool   `json:"invite_required,omitempty"` // privacy settings only allow joining by invite
+}
+
+// CreatedGroup is the result of creating a group
+type CreatedGroup struct {
+	Group        GroupInfo                `json:"group"`
+	Participants []GroupParticipantResult `json:"participants"`
+}
+
+// GroupInviteLink is a group's invite link
+type GroupInviteLink struct {
+	GroupJID   string `json:"group_jid"`
+	InviteLink string `json:"invite_link"`
+}
+
+// JoinedGroup is the result of joining a group by invite link
+type JoinedGroup struct {
+	GroupJID        string `json:"group_jid"`
+	PendingApproval bool   `json:"pending_approval"` // an admin has to approve the join request
+}
+
+// GroupJoinRequest is a pending request to join a group
+type GroupJoinRequest struct {
+	JID         string    `json:"jid"`
+	LID         string    `json:"lid,omitempty"`
+	Name        string    `json:"name,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// SendMediaRequest describes a media message to send. The media comes from either
+// FilePath or Data (base64), and MediaType is inferred from the MIME type when empty.
+type SendMediaRequest struct {
+	Recipient string `json:"recipient"`
+	FilePath  string `json:"file_path"`
+	Data      string `json:"data"`
+	MediaType string `json:"media_type"` // image, video, audio, document or sticker
+	MimeType  string `json:"mime_type"`
+	Caption   string `json:"caption"`
+	Filename  string `json:"filename"`
+	PTT       bool   `json:"ptt"`       // send audio as a voice note
+	Thumbnail string `json:"thumbnail"` // base64 preview image for videos
+}
+
+// MediaFile is a downloaded message attachment
+type MediaFile struct {
+	MessageID string `json:"message_id"`
+	ChatJID   string `json:"chat_jid"`
+	MediaType string `json:"media_type"`
+	Path      string `json:"path"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`
+	FileName  string `json:"file_name,omitempty"`
+	Width     uint32 `json:"width,omitempty"`
+	Height    uint32 `json:"height,omitempty"`
+	Duration  uint32 `json:"duration,omitempty"` // seconds
+	IsVoice   bool   `json:"is_voice,omitempty"`
+}
+
+// ReadResult reports how many messages were marked as read and how many remain unread
+type ReadResult struct {
+	ChatJID     string `json:"chat_jid"`
+	MarkedRead  int    `json:"marked_read"`
+	UnreadCount int    `json:"unread_count"`
+}
+
+// MessageStatus is the delivery state of a message we sent. Status is the least
+// advanced state across recipients: sent, delivered, read or played.
+type MessageStatus struct {
+	MessageID  string            `json:"message_id"`
+	ChatJID    string            `json:"chat_jid"`
+	SentAt     time.Time         `json:"sent_at"`
+	Status     string            `json:"status"`
+	Recipients []RecipientStatus `json:"recipients"`
+}
+
+// RecipientStatus is how far a message got with a single recipient
+type RecipientStatus struct {
+	JID         string     `json:"jid"`
+	Status      string     `json:"status"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	PlayedAt    *time.Time `json:"played_at,omitempty"`
+}
+
+// ContactPresence is the last known online status of a contact. LastSeen is
+// missing when the contact hides it, and UpdatedAt when no update arrived yet.
+type ContactPresence struct {
+	JID        string     `json:"jid"`
+	Online     bool       `json:"online"`
+	LastSeen   *time.Time `json:"last_seen,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	Subscribed bool       `json:"subscribed"`
+}
+
+// AccountStatus describes a linked WhatsApp account. State is only set by
+// list_accounts; from the status command Connected and LastSeen are as last
+// recorded by a running server.
+type AccountStatus struct {
+	Alias        string     `json:"alias"`
+	JID          string     `json:"jid,omitempty"`
+	PushName     string     `json:"push_name,omitempty"`
+	Platform     string     `json:"platform,omitempty"`
+	BusinessName string     `json:"business_name,omitempty"`
+	State        string     `json:"state,omitempty"`
+	Connected    bool       `json:"connected"`
+	LastSeen     *time.Time `json:"last_seen,omitempty"`
+}
+
+// NumberCheck is the check_numbers result for one phone number. Phone is the
+// number in E.164 format, or Error says why it couldn't be read.
+type NumberCheck struct {
+	Input        string `json:"input"`
+	Phone        string `json:"phone,omitempty"`
+	Registered   bool   `json:"registered"`
+	JID          string `json:"jid,omitempty"`
+	IsBusiness   bool   `json:"is_business"`
+	VerifiedName string `json:"verified_name,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// ConnectionStatus is the state of the WhatsApp connection as reported by connection_status
+type ConnectionStatus struct {
+	State          string            `json:"state"`
+	Since          time.Time         `json:"since"`
+	Connected      bool              `json:"connected"`
+	JID            string            `json:"jid,omitempty"`
+	LastError      string            `json:"last_error,omitempty"`
+	LastErrorAt    *time.Time        `json:"last_error_at,omitempty"`
+	ReconnectCount int               `json:"reconnect_count"`
+	Attempt        int               `json:"attempt,omitempty"`
+	NextRetry      *time.Time        `json:"next_retry,omitempty"`
+	History        []ConnectionEvent `json:"history"`
+}
+
+// ConnectionEvent is one change of the connection state, oldest first in the history
+type ConnectionEvent struct {
+	State string    `json:"state"`
+	At    time.Time `json:"at"`
+	Error string    `json:"error,omitempty"`
 }
@@ -0,0 +1,45 @@
+package whatsapp
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// uploadPath resolves a file path given to a tool, relative to uploadDir unless
+// absolute, and checks that it is inside uploadDir once symlinks are followed.
+// Tools can only send out files that were put there for them, not anything else
+// the server can read, like its own session database.
+func uploadPath(uploadDir, path string) (string, error) {
+	if uploadDir == "" {
+		return "", fmt.Errorf("sending local files is disabled, set --upload-dir to allow it")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(uploadDir, path)
+	}
+	return resolveWithin(uploadDir, path)
+}
+
+// resolveWithin resolves the symlinks in path and returns the result if it is
+// inside dir, whose own symlinks are resolved as well
+func resolveWithin(dir, path string) (string, error) {
+	root, err := filepath.Abs(dir)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve directory %s: %w", dir, err)
+	}
+	resolved, err := filepath.Abs(path)
+	if err == nil {
+		resolved, err = filepath.EvalSymlinks(resolved)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside %s", path, dir)
+	}
+	return resolved, nil
+}
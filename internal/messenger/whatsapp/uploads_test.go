@@ -0,0 +1,56 @@
+package whatsapp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadPath(t *testing.T) {
+	root := t.TempDir()
+	uploads := filepath.Join(root, "uploads")
+	if err := os.MkdirAll(filepath.Join(uploads, "photos"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join(uploads, "photos", "group.jpg"), filepath.Join(root, "secret.db")} {
+		if err := os.WriteFile(name, []byte("x"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.db"), filepath.Join(uploads, "link.jpg")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"relative", "photos/group.jpg", false},
+		{"absolute", filepath.Join(uploads, "photos", "group.jpg"), false},
+		{"missing", "photos/missing.jpg", true},
+		{"parent directory", "../secret.db", true},
+		{"absolute outside", filepath.Join(root, "secret.db"), true},
+		{"symlink out", "link.jpg", true},
+	}
+	want, err := filepath.EvalSymlinks(filepath.Join(uploads, "photos", "group.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got, err := uploadPath(uploads, tt.path)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: uploadPath(%q) = %q, want an error", tt.name, tt.path, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("%s: uploadPath(%q) = %q, %v; want %q", tt.name, tt.path, got, err, want)
+		}
+	}
+
+	if _, err := uploadPath("", filepath.Join(uploads, "photos", "group.jpg")); err == nil {
+		t.Error("uploadPath succeeded without an upload directory")
+	}
+}
@@ -2,18 +2,19 @@ package whatsapp
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
-	"os"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	_ "github.com/mattn/go-sqlite3"
-	qrterminal "github.com/mdp/qrterminal/v3"
 	"github.com/rs/zerolog/log"
-	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/store/sqlstore"
 	"go.mau.fi/whatsmeow/types"
@@ -21,79 +22,113 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// WhatsAppMessenger implements the Messenger interface for WhatsApp
+const (
+	// defaultListLimit is how many results the list tools return unless asked otherwise
+	defaultListLimit = 20
+
+	// maxListLimit caps the list tools' limit. Larger pages flood the caller, and
+	// looking up reactions binds two SQL variables per message, of which SQLite
+	// only allows so many.
+	maxListLimit = 200
+)
+
+// WhatsAppMessenger implements the Messenger interface for WhatsApp. It runs
+// every device linked in the session database as a separate account.
 type WhatsAppMessenger struct {
-	client    *whatsmeow.Client
+	config    WhatsAppConfig
 	container *sqlstore.Container
-	deviceDB  string
+	db        *sql.DB
+
+	mu       sync.RWMutex
+	accounts map[string]*account
+	pairing  map[string]*pairing
 }
 
 // NewWhatsAppMessenger creates a new WhatsApp messenger instance
-func NewWhatsAppMessenger(deviceDB string) (*WhatsAppMessenger, error) {
-	// Create a simple logger adapter for whatsmeow
-	waLogger := waLog.Stdout("WhatsApp", "INFO", true)
+func NewWhatsAppMessenger(config WhatsAppConfig) (*WhatsAppMessenger, error) {
+	config.DefaultCountryCode = strings.TrimPrefix(config.DefaultCountryCode, "+")
+	if err := validateCountryCode(config.DefaultCountryCode); err != nil {
+		return nil, err
+	}
+
+	// whatsmeow logs through zerolog to stderr, as stdout carries the MCP stdio transport
+	waLogger := waLog.Zerolog(log.With().Str("module", "WhatsApp").Logger())
 
-	container, err := sqlstore.New(context.Background(), "sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", deviceDB), waLogger)
+	// The session database also holds the account aliases, so it is opened here
+	// and shared with the sqlstore container
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", config.DeviceDB))
 	if err != nil {
+		return nil, fmt.Errorf("failed to open device database: %w", err)
+	}
+	container := sqlstore.NewWithDB(db, "sqlite3", waLogger)
+	if err := container.Upgrade(context.Background()); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create SQLite container: %w", err)
 	}
+	if _, err := db.Exec(accountsTable); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create accounts table: %w", err)
+	}
 
 	return &WhatsAppMessenger{
+		config:    config,
 		container: container,
-		deviceDB:  deviceDB,
+		db:        db,
+		accounts:  make(map[string]*account),
+		pairing:   make(map[string]*pairing),
 	}, nil
 }
 
-// Connect establishes connection to WhatsApp
+// Connect connects every linked account. Accounts must already be linked with
+// Login or add_account, as the MCP server can't show a QR code on startup.
+// Accounts that fail to connect keep retrying in the background.
 func (w *WhatsAppMessenger) Connect(ctx context.Context) error {
-	deviceStore, err := w.container.GetFirstDevice(ctx)
+	devices, err := w.linkedDevices(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get device: %w", err)
+		return err
 	}
-
-	if deviceStore == nil {
-		log.Info().Msg("No device found, creating new device")
-		deviceStore = w.container.NewDevice()
+	if len(devices) == 0 {
+		return fmt.Errorf("not logged in to WhatsApp, run \"multichat login\" first")
 	}
 
-	w.client = whatsmeow.NewClient(deviceStore, nil)
-
-	if w.client.Store.ID == nil {
-		// No ID stored, new login required
-		qrChan, err := w.client.GetQRChannel(ctx)
+	// Every account is set up before any is started, so a failure leaves nothing running
+	aliases := slices.Sorted(maps.Keys(devices))
+	accounts := make([]*account, 0, len(aliases))
+	for _, alias := range aliases {
+		a, err := newAccount(alias, accountConfig(w.config, alias), devices[alias])
 		if err != nil {
-			return fmt.Errorf("failed to get QR channel: %w", err)
-		}
-
-		err = w.client.Connect()
-		if err != nil {
-			return fmt.Errorf("failed to connect: %w", err)
-		}
-
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				log.Info().Msg("QR code received, displaying for WhatsApp scan...")
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-				log.Info().Msg("Scan the QR code above with WhatsApp to log in")
-			} else {
-				log.Info().Str("event", evt.Event).Msg("Login event")
+			for _, a := range accounts {
+				a.store.Close()
 			}
+			return err
 		}
-	} else {
-		err = w.client.Connect()
-		if err != nil {
-			return fmt.Errorf("failed to connect: %w", err)
-		}
+		accounts = append(accounts, a)
 	}
 
-	log.Info().Msg("WhatsApp connected successfully")
+	w.mu.Lock()
+	for _, a := range accounts {
+		w.accounts[a.alias] = a
+	}
+	w.mu.Unlock()
+	for _, a := range accounts {
+		a.start()
+	}
 	return nil
 }
 
-// Disconnect closes the WhatsApp connection
+// Disconnect closes the connections of all accounts
 func (w *WhatsAppMessenger) Disconnect() error {
-	if w.client != nil {
-		w.client.Disconnect()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// finishPairing cleans up accounts still being linked once cancelled
+	for alias, p := range w.pairing {
+		p.cancel()
+		delete(w.pairing, alias)
+	}
+	for alias, a := range w.accounts {
+		a.stop()
+		delete(w.accounts, alias)
 	}
 	if w.container != nil {
 		return w.container.Close()
@@ -106,143 +141,126 @@ func (w *WhatsAppMessenger) GetMessengerName() string {
 	return "whatsapp"
 }
 
-// SearchContacts searches for contacts by name or phone number
-func (w *WhatsAppMessenger) searchContacts(ctx context.Context, query string) ([]Contact, error) {
-	if !w.IsConnected() {
-		return nil, fmt.Errorf("not connected to WhatsApp")
+// listMessages retrieves messages with optional filters
+func (a *account) listMessages(ctx context.Context, filter MessageFilter) (*MessagePage, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
 	}
 
-	contacts, err := w.client.Store.Contacts.GetAllContacts(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get contacts: %w", err)
-	}
+	// Senders and chats are stored under their phone number when it is known
+	filter.SenderJID = a.canonicalJID(ctx, filter.SenderJID)
+	filter.ChatJID = a.canonicalJID(ctx, filter.ChatJID)
+	return a.store.listMessages(ctx, filter)
+}
 
-	var results []Contact
-	query = strings.ToLower(query)
+// getMessageContext returns a message with up to count messages before and after it in its chat
+func (a *account) getMessageContext(ctx context.Context, chatJID, messageID string, count int) (*MessageContext, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
+	}
 
-	for jid, contact := range contacts {
-		name := strings.ToLower(contact.FullName)
-		phone := jid.User
+	jid, err := a.parseJID(ctx, chatJID)
+	if err != nil {
+		return nil, err
+	}
 
-		if strings.Contains(name, query) || strings.Contains(phone, query) {
-			results = append(results, Contact{
-				JID:         jid.String(),
-				PhoneNumber: phone,
-				Name:        contact.FullName,
-			})
-		}
+	msgContext, err := a.store.messageContext(ctx, jid.String(), messageID, count)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message context: %w", err)
+	}
+	if msgContext == nil {
+		return nil, fmt.Errorf("message %s not found in chat %s", messageID, jid)
 	}
 
-	return results, nil
+	return msgContext, nil
 }
 
-// listMessages retrieves messages with optional filters
-func (w *WhatsAppMessenger) listMessages(ctx context.Context, filter MessageFilter) ([]Message, error) {
-	if !w.IsConnected() {
-		return nil, fmt.Errorf("not connected to WhatsApp")
+// listChats lists chats ordered by most recent activity, optionally only unread ones
+func (a *account) listChats(ctx context.Context, limit int, cursor string, unreadOnly bool) (*ChatPage, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
 	}
 
-	// Note: whatsmeow doesn't provide direct message history access
-	// You would need to implement message caching/storage separately
-	// This is a placeholder implementation
-	log.Warn().Msg("ListMessages: message history not fully implemented - requires custom message storage")
+	page, err := a.store.listChats(ctx, limit, cursor, unreadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list chats: %w", err)
+	}
 
-	return []Message{}, nil
+	for i := range page.Chats {
+		a.fillChatName(ctx, &page.Chats[i])
+	}
+
+	return page, nil
 }
 
-// listChats lists available chats
-func (w *WhatsAppMessenger) listChats(ctx context.Context, limit, page int) ([]Chat, error) {
-	if !w.IsConnected() {
-		return nil, fmt.Errorf("not connected to WhatsApp")
+// getChat gets information about a specific chat
+func (a *account) getChat(ctx context.Context, chatJID string) (*Chat, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
 	}
 
-	// Get all contacts as a proxy for chats
-	contacts, err := w.client.Store.Contacts.GetAllContacts(ctx)
+	jid, err := a.parseJID(ctx, chatJID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get contacts: %w", err)
+		return nil, err
 	}
 
-	var chats []Chat
-	for jid, contact := range contacts {
-		chat := Chat{
+	chat, err := a.store.getChat(ctx, jid.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat == nil {
+		// Nothing stored yet, describe the chat from its JID alone
+		chat = &Chat{
 			JID:     jid.String(),
 			IsGroup: jid.Server == types.GroupServer,
-			Name:    contact.FullName,
 		}
-
-		if chat.Name == "" {
-			chat.Name = jid.User
-		}
-
-		chats = append(chats, chat)
 	}
 
-	// Apply pagination
-	start := page * limit
-	if start >= len(chats) {
-		return []Chat{}, nil
-	}
-
-	end := start + limit
-	if end > len(chats) {
-		end = len(chats)
-	}
-
-	return chats[start:end], nil
+	a.fillChatName(ctx, chat)
+	return chat, nil
 }
 
-// getChat gets information about a specific chat
-func (w *WhatsAppMessenger) getChat(ctx context.Context, chatJID string) (*Chat, error) {
-	if !w.IsConnected() {
-		return nil, fmt.Errorf("not connected to WhatsApp")
+// fillChatName falls back to the contact name, then the bare number, for chats without a name
+func (a *account) fillChatName(ctx context.Context, chat *Chat) {
+	if chat.Name != "" {
+		return
 	}
 
-	jid, err := types.ParseJID(chatJID)
+	jid, err := types.ParseJID(chat.JID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid JID: %w", err)
-	}
-
-	chat := &Chat{
-		JID:     jid.String(),
-		IsGroup: jid.Server == types.GroupServer,
+		chat.Name = chat.JID
+		return
 	}
 
-	if contact, err := w.client.Store.Contacts.GetContact(ctx, jid); err == nil {
-		chat.Name = contact.FullName
-	}
+	chat.Name = a.contactName(ctx, jid)
 	if chat.Name == "" {
 		chat.Name = jid.User
 	}
-
-	return chat, nil
 }
 
 // getDirectChatByContact finds a direct chat with a specific contact
-func (w *WhatsAppMessenger) getDirectChatByContact(ctx context.Context, phoneNumber string) (*Chat, error) {
-	if !w.IsConnected() {
-		return nil, fmt.Errorf("not connected to WhatsApp")
+func (a *account) getDirectChatByContact(ctx context.Context, phoneNumber string) (*Chat, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
 	}
 
-	// Remove any non-numeric characters
-	phone := strings.Map(func(r rune) rune {
-		if r >= '0' && r <= '9' {
-			return r
-		}
-		return -1
-	}, phoneNumber)
+	phone, err := normalizePhone(phoneNumber, a.config.DefaultCountryCode)
+	if err != nil {
+		return nil, err
+	}
 
-	jid := types.NewJID(phone, types.DefaultUserServer)
-	return w.getChat(ctx, jid.String())
+	jid := types.NewJID(strings.TrimPrefix(phone, "+"), types.DefaultUserServer)
+	return a.getChat(ctx, jid.String())
 }
 
 // getContactChats lists all chats involving a specific contact
-func (w *WhatsAppMessenger) getContactChats(ctx context.Context, contactJID string) ([]Chat, error) {
-	if !w.IsConnected() {
-		return nil, fmt.Errorf("not connected to WhatsApp")
+func (a *account) getContactChats(ctx context.Context, contactJID string) ([]Chat, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
 	}
 
 	// For direct messages, just return the direct chat
-	chat, err := w.getChat(ctx, contactJID)
+	chat, err := a.getChat(ctx, contactJID)
 	if err != nil {
 		return nil, err
 	}
@@ -250,72 +268,135 @@ func (w *WhatsAppMessenger) getContactChats(ctx context.Context, contactJID stri
 	return []Chat{*chat}, nil
 }
 
-// sendMessage sends a message to a chat
-func (w *WhatsAppMessenger) sendMessage(ctx context.Context, recipient, message string) error {
-	if !w.IsConnected() {
-		return fmt.Errorf("not connected to WhatsApp")
+// sendMessage sends a message to a chat, optionally as a reply quoting one of its messages
+func (a *account) sendMessage(ctx context.Context, recipient, message, replyTo string) (*Message, error) {
+	if err := a.ensureConnected(); err != nil {
+		return nil, err
 	}
 
-	// Parse recipient as JID or phone number
-	var jid types.JID
-	var err error
+	jid, err := a.verifyRecipient(ctx, recipient)
+	if err != nil {
+		return nil, err
+	}
 
-	if strings.Contains(recipient, "@") {
-		jid, err = types.ParseJID(recipient)
+	msg := &waProto.Message{
+		Conversation: proto.String(message),
+	}
+	if replyTo != "" {
+		// Plain conversation messages can't carry a quote, so replies need the extended form
+		ctxInfo, err := a.replyContext(ctx, jid, replyTo)
 		if err != nil {
-			return fmt.Errorf("invalid JID: %w", err)
+			return nil, err
+		}
+		msg = &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String(message),
+				ContextInfo: ctxInfo,
+			},
 		}
-	} else {
-		// Assume it's a phone number
-		phone := strings.Map(func(r rune) rune {
-			if r >= '0' && r <= '9' {
-				return r
-			}
-			return -1
-		}, recipient)
-		jid = types.NewJID(phone, types.DefaultUserServer)
 	}
 
-	msg := &waProto.Message{
-		Conversation: proto.String(message),
+	sent, err := a.sendAndStore(ctx, jid, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
 	}
 
-	_, err = w.client.SendMessage(ctx, jid, msg)
+	log.Info().Str("recipient", jid.String()).Str("id", sent.ID).Msg("Message sent")
+	return sent, nil
+}
+
+// replyContext builds the context info that quotes a stored message of a chat
+func (a *account) replyContext(ctx context.Context, chat types.JID, messageID string) (*waProto.ContextInfo, error) {
+	original, err := a.store.getMessage(ctx, chat.ToNonAD().String(), messageID)
 	if err != nil {
-		return fmt.Errorf("failed to send message: %w", err)
+		return nil, fmt.Errorf("failed to look up quoted message: %w", err)
+	}
+	if original == nil {
+		return nil, fmt.Errorf("message %s not found in chat %s", messageID, chat.ToNonAD())
 	}
 
-	log.Info().Str("recipient", jid.String()).Msg("Message sent")
-	return nil
+	// Media is quoted with its attachment so recipients see the thumbnail and caption
+	quoted := original.media
+	if quoted == nil {
+		quoted = &waProto.Message{Conversation: proto.String(original.Text)}
+	}
+
+	sender, err := a.keySender(ctx, original, a.chatUsesLIDs(chat))
+	if err != nil {
+		return nil, err
+	}
+
+	return &waProto.ContextInfo{
+		StanzaID:      proto.String(original.ID),
+		Participant:   proto.String(sender.String()),
+		QuotedMessage: quoted,
+	}, nil
+}
+
+// sendAndStore sends a message and records it in the message store
+func (a *account) sendAndStore(ctx context.Context, jid types.JID, msg *waProto.Message) (*Message, error) {
+	resp, err := a.client.SendMessage(ctx, jid, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	// whatsmeow doesn't emit events for our own sends, so record the message here
+	sent := Message{
+		ID:        resp.ID,
+		ChatJID:   jid.String(),
+		Sender:    a.client.Store.GetJID().ToNonAD().String(),
+		Text:      messageText(msg),
+		Timestamp: resp.Timestamp,
+		IsFromMe:  true,
+		ReplyTo:   quotedMessage(msg),
+	}
+	sent.MediaType, sent.media = messageMedia(msg)
+	if err := a.store.saveMessage(ctx, sent); err != nil {
+		log.Error().Err(err).Str("id", sent.ID).Msg("Failed to store sent message")
+	}
+
+	return &sent, nil
 }
 
-// IsConnected returns the connection status
+// IsConnected reports whether any account is connected
 func (w *WhatsAppMessenger) IsConnected() bool {
-	return w.client != nil && w.client.IsConnected()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	for _, a := range w.accounts {
+		if a.isConnected() {
+			return true
+		}
+	}
+	return false
 }
 
 // RegisterMCPTools registers WhatsApp-specific MCP tools
 func (w *WhatsAppMessenger) RegisterMCPTools(mcpServer *server.MCPServer) {
 	// search_contacts
-	mcpServer.AddTool(mcp.Tool{
+	w.addTool(mcpServer, mcp.Tool{
 		Name:        "search_contacts",
-		Description: "Search for contacts by name or phone number",
+		Description: "Search for contacts by name, phone number or JID. Full, first, business and push names are matched ignoring case and accents and allowing for typos. Results are ranked by match quality and how recently you talked to the contact, and say which field matched. Contacts known by LID are listed under their phone number when it is known, with the LID alongside.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
 				"query": map[string]interface{}{
 					"type":        "string",
-					"description": "Search term to match against contact names or phone numbers",
+					"description": "Search term to match against contact names or phone numbers, or a phone number or LID JID",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of contacts to return, at most %d", maxListLimit),
+					"default":     defaultListLimit,
 				},
 			},
 			Required: []string{"query"},
 		},
-	}, w.handleSearchContacts)
+	}, (*account).handleSearchContacts)
 
 	// list_messages
-	mcpServer.AddTool(mcp.Tool{
+	w.addTool(mcpServer, mcp.Tool{
 		Name:        "list_messages",
-		Description: "Retrieve messages with optional filters (e.g. time, sender) and context",
+		Description: "Retrieve messages with optional filters (e.g. time, sender) and context. Replies carry a reply_to object with the quoted message.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
@@ -337,45 +418,73 @@ func (w *WhatsAppMessenger) RegisterMCPTools(mcpServer *server.MCPServer) {
 				},
 				"query": map[string]interface{}{
 					"type":        "string",
-					"description": "Search term to filter messages by content",
+					"description": "Full-text search over message content, ranked by relevance. Accents are ignored; supports \"exact phrases\", prefix* matches, -excluded terms and OR. Combine with chat_jid or sender_jid to scope the search",
 				},
 				"limit": map[string]interface{}{
 					"type":        "integer",
-					"description": "Maximum number of messages to return",
-					"default":     20,
+					"description": fmt.Sprintf("Maximum number of messages to return, at most %d", maxListLimit),
+					"default":     defaultListLimit,
+				},
+				"cursor": map[string]interface{}{
+					"type":        "string",
+					"description": "Opaque next_cursor or prev_cursor from a previous call. next_cursor continues with older messages (or lower-ranked search hits), prev_cursor goes back towards newer ones. Keep the other filters unchanged when paging",
+				},
+			},
+		},
+	}, (*account).handleListMessages)
+
+	// get_message_context
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "get_message_context",
+		Description: "Get the conversation around a message: up to count messages before and after it in the same chat, oldest first. Replies include the quoted message inline",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"chat_jid": map[string]interface{}{
+					"type":        "string",
+					"description": "The JID of the chat containing the message",
 				},
-				"page": map[string]interface{}{
+				"message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "The ID of the message to center on",
+				},
+				"count": map[string]interface{}{
 					"type":        "integer",
-					"description": "Page number for pagination",
-					"default":     0,
+					"description": "Number of messages to return on each side of the message (max 50)",
+					"default":     5,
 				},
 			},
+			Required: []string{"chat_jid", "message_id"},
 		},
-	}, w.handleListMessages)
+	}, (*account).handleGetMessageContext)
 
 	// list_chats
-	mcpServer.AddTool(mcp.Tool{
+	w.addTool(mcpServer, mcp.Tool{
 		Name:        "list_chats",
-		Description: "List available chats with metadata (name, JID, last message)",
+		Description: "List chats (direct and group) ordered by most recent activity, with last message, unread count and archived/pinned/muted flags",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
 				"limit": map[string]interface{}{
 					"type":        "integer",
-					"description": "Maximum number of chats to return",
-					"default":     20,
+					"description": fmt.Sprintf("Maximum number of chats to return, at most %d", maxListLimit),
+					"default":     defaultListLimit,
 				},
-				"page": map[string]interface{}{
-					"type":        "integer",
-					"description": "Page number for pagination",
-					"default":     0,
+				"cursor": map[string]interface{}{
+					"type":        "string",
+					"description": "Opaque next_cursor or prev_cursor from a previous call. next_cursor continues with less recently active chats, prev_cursor goes back towards more recent ones",
+				},
+				"unread_only": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only return chats with unread messages",
+					"default":     false,
 				},
 			},
 		},
-	}, w.handleListChats)
+	}, (*account).handleListChats)
 
 	// get_chat
-	mcpServer.AddTool(mcp.Tool{
+	w.addTool(mcpServer, mcp.Tool{
 		Name:        "get_chat",
 		Description: "Get information about a specific chat (metadata, messages)",
 		InputSchema: mcp.ToolInputSchema{
@@ -388,10 +497,10 @@ func (w *WhatsAppMessenger) RegisterMCPTools(mcpServer *server.MCPServer) {
 			},
 			Required: []string{"chat_jid"},
 		},
-	}, w.handleGetChat)
+	}, (*account).handleGetChat)
 
 	// get_direct_chat_by_contact
-	mcpServer.AddTool(mcp.Tool{
+	w.addTool(mcpServer, mcp.Tool{
 		Name:        "get_direct_chat_by_contact",
 		Description: "Find a direct chat with a specific contact by phone number",
 		InputSchema: mcp.ToolInputSchema{
@@ -399,15 +508,15 @@ func (w *WhatsAppMessenger) RegisterMCPTools(mcpServer *server.MCPServer) {
 			Properties: map[string]interface{}{
 				"phone_number": map[string]interface{}{
 					"type":        "string",
-					"description": "Phone number of the contact (with country code, no + or spaces)",
+					"description": "Phone number of the contact. Numbers without a country code use the default country code.",
 				},
 			},
 			Required: []string{"phone_number"},
 		},
-	}, w.handleGetDirectChatByContact)
+	}, (*account).handleGetDirectChatByContact)
 
 	// get_contact_chats
-	mcpServer.AddTool(mcp.Tool{
+	w.addTool(mcpServer, mcp.Tool{
 		Name:        "get_contact_chats",
 		Description: "List all chats involving a specific contact",
 		InputSchema: mcp.ToolInputSchema{
@@ -420,41 +529,67 @@ func (w *WhatsAppMessenger) RegisterMCPTools(mcpServer *server.MCPServer) {
 			},
 			Required: []string{"contact_jid"},
 		},
-	}, w.handleGetContactChats)
+	}, (*account).handleGetContactChats)
 
 	// send_message
-	mcpServer.AddTool(mcp.Tool{
+	w.addTool(mcpServer, mcp.Tool{
 		Name:        "send_message",
-		Description: "Send a WhatsApp message to a specified phone number or group JID",
+		Description: "Send a WhatsApp message to a specified phone number or group JID. Returns the sent message, including the ID needed to edit or delete it.",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
 				"recipient": map[string]interface{}{
 					"type":        "string",
-					"description": "Phone number (with country code) or JID of the recipient",
+					"description": "Phone number or JID of the recipient. Numbers without a country code use the default country code.",
 				},
 				"message": map[string]interface{}{
 					"type":        "string",
 					"description": "The message text to send",
 				},
+				"reply_to_message_id": map[string]interface{}{
+					"type":        "string",
+					"description": "ID of a message in the same chat to reply to; the reply quotes it",
+				},
 			},
 			Required: []string{"recipient", "message"},
 		},
-	}, w.handleSendMessage)
+	}, (*account).handleSendMessage)
+
+	// get_history_sync_status
+	w.addTool(mcpServer, mcp.Tool{
+		Name:        "get_history_sync_status",
+		Description: "Show how far the initial WhatsApp history sync has progressed (chunks, conversations and messages stored per sync type)",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, (*account).handleGetHistorySyncStatus)
+
+	w.registerGroupTools(mcpServer)
+	w.registerInviteTools(mcpServer)
+	w.registerMediaTools(mcpServer)
+	w.registerEditTools(mcpServer)
+	w.registerReactionTools(mcpServer)
+	w.registerReceiptTools(mcpServer)
+	w.registerPresenceTools(mcpServer)
+	w.registerConnectionTools(mcpServer)
+	w.registerPhoneTools(mcpServer)
+	w.registerAccountTools(mcpServer)
 }
 
 // Tool handlers
 
-func (w *WhatsAppMessenger) handleSearchContacts(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (a *account) handleSearchContacts(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		Query string `json:"query"`
+		Limit int    `json:"limit"`
 	}
 	argsBytes, _ := json.Marshal(request.Params.Arguments)
 	if err := json.Unmarshal(argsBytes, &args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	contacts, err := w.searchContacts(ctx, args.Query)
+	contacts, err := a.searchContacts(ctx, args.Query, listLimit(args.Limit))
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
 	}
@@ -463,7 +598,15 @@ func (w *WhatsAppMessenger) handleSearchContacts(ctx context.Context, request mc
 	return mcp.NewToolResultText(string(result)), nil
 }
 
-func (w *WhatsAppMessenger) handleListMessages(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// listLimit returns the limit a list tool was called with, defaulted and capped
+func listLimit(limit int) int {
+	if limit <= 0 {
+		return defaultListLimit
+	}
+	return min(limit, maxListLimit)
+}
+
+func (a *account) handleListMessages(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		After     string `json:"after"`
 		Before    string `json:"before"`
@@ -471,23 +614,21 @@ func (w *WhatsAppMessenger) handleListMessages(ctx context.Context, request mcp.
 		ChatJID   string `json:"chat_jid"`
 		Query     string `json:"query"`
 		Limit     int    `json:"limit"`
-		Page      int    `json:"page"`
+		Cursor    string `json:"cursor"`
 	}
 	argsBytes, _ := json.Marshal(request.Params.Arguments)
 	if err := json.Unmarshal(argsBytes, &args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	if args.Limit == 0 {
-		args.Limit = 20
-	}
+	args.Limit = listLimit(args.Limit)
 
 	filter := MessageFilter{
 		SenderJID: args.SenderJID,
 		ChatJID:   args.ChatJID,
 		Query:     args.Query,
 		Limit:     args.Limit,
-		Page:      args.Page,
+		Cursor:    args.Cursor,
 	}
 
 	if args.After != "" {
@@ -506,39 +647,65 @@ func (w *WhatsAppMessenger) handleListMessages(ctx context.Context, request mcp.
 		filter.Before = &t
 	}
 
-	messages, err := w.listMessages(ctx, filter)
+	page, err := a.listMessages(ctx, filter)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("list messages failed: %v", err)), nil
 	}
 
-	result, _ := json.Marshal(messages)
+	result, _ := json.Marshal(page)
 	return mcp.NewToolResultText(string(result)), nil
 }
 
-func (w *WhatsAppMessenger) handleListChats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (a *account) handleGetMessageContext(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
-		Limit int `json:"limit"`
-		Page  int `json:"page"`
+		ChatJID   string `json:"chat_jid"`
+		MessageID string `json:"message_id"`
+		Count     int    `json:"count"`
 	}
 	argsBytes, _ := json.Marshal(request.Params.Arguments)
 	if err := json.Unmarshal(argsBytes, &args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	if args.Limit == 0 {
-		args.Limit = 20
+	if args.Count <= 0 {
+		args.Count = 5
+	}
+	if args.Count > 50 {
+		args.Count = 50
+	}
+
+	msgContext, err := a.getMessageContext(ctx, args.ChatJID, args.MessageID, args.Count)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("get message context failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(msgContext)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleListChats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var args struct {
+		Limit      int    `json:"limit"`
+		Cursor     string `json:"cursor"`
+		UnreadOnly bool   `json:"unread_only"`
+	}
+	argsBytes, _ := json.Marshal(request.Params.Arguments)
+	if err := json.Unmarshal(argsBytes, &args); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	chats, err := w.listChats(ctx, args.Limit, args.Page)
+	args.Limit = listLimit(args.Limit)
+
+	page, err := a.listChats(ctx, args.Limit, args.Cursor, args.UnreadOnly)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("list chats failed: %v", err)), nil
 	}
 
-	result, _ := json.Marshal(chats)
+	result, _ := json.Marshal(page)
 	return mcp.NewToolResultText(string(result)), nil
 }
 
-func (w *WhatsAppMessenger) handleGetChat(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (a *account) handleGetChat(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		ChatJID string `json:"chat_jid"`
 	}
@@ -547,7 +714,7 @@ func (w *WhatsAppMessenger) handleGetChat(ctx context.Context, request mcp.CallT
 		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	chat, err := w.getChat(ctx, args.ChatJID)
+	chat, err := a.getChat(ctx, args.ChatJID)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("get chat failed: %v", err)), nil
 	}
@@ -556,7 +723,7 @@ func (w *WhatsAppMessenger) handleGetChat(ctx context.Context, request mcp.CallT
 	return mcp.NewToolResultText(string(result)), nil
 }
 
-func (w *WhatsAppMessenger) handleGetDirectChatByContact(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (a *account) handleGetDirectChatByContact(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		PhoneNumber string `json:"phone_number"`
 	}
@@ -565,7 +732,7 @@ func (w *WhatsAppMessenger) handleGetDirectChatByContact(ctx context.Context, re
 		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	chat, err := w.getDirectChatByContact(ctx, args.PhoneNumber)
+	chat, err := a.getDirectChatByContact(ctx, args.PhoneNumber)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("get direct chat failed: %v", err)), nil
 	}
@@ -574,7 +741,7 @@ func (w *WhatsAppMessenger) handleGetDirectChatByContact(ctx context.Context, re
 	return mcp.NewToolResultText(string(result)), nil
 }
 
-func (w *WhatsAppMessenger) handleGetContactChats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (a *account) handleGetContactChats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
 		ContactJID string `json:"contact_jid"`
 	}
@@ -583,7 +750,7 @@ func (w *WhatsAppMessenger) handleGetContactChats(ctx context.Context, request m
 		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	chats, err := w.getContactChats(ctx, args.ContactJID)
+	chats, err := a.getContactChats(ctx, args.ContactJID)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("get contact chats failed: %v", err)), nil
 	}
@@ -592,20 +759,32 @@ func (w *WhatsAppMessenger) handleGetContactChats(ctx context.Context, request m
 	return mcp.NewToolResultText(string(result)), nil
 }
 
-func (w *WhatsAppMessenger) handleSendMessage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+func (a *account) handleSendMessage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	var args struct {
-		Recipient string `json:"recipient"`
-		Message   string `json:"message"`
+		Recipient        string `json:"recipient"`
+		Message          string `json:"message"`
+		ReplyToMessageID string `json:"reply_to_message_id"`
 	}
 	argsBytes, _ := json.Marshal(request.Params.Arguments)
 	if err := json.Unmarshal(argsBytes, &args); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
 	}
 
-	err := w.sendMessage(ctx, args.Recipient, args.Message)
+	sent, err := a.sendMessage(ctx, args.Recipient, args.Message, args.ReplyToMessageID)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("send message failed: %v", err)), nil
 	}
 
-	return mcp.NewToolResultText("Message sent successfully"), nil
+	result, _ := json.Marshal(sent)
+	return mcp.NewToolResultText(string(result)), nil
+}
+
+func (a *account) handleGetHistorySyncStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	statuses, err := a.getHistorySyncStatus(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("get history sync status failed: %v", err)), nil
+	}
+
+	result, _ := json.Marshal(statuses)
+	return mcp.NewToolResultText(string(result)), nil
 }
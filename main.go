@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -20,8 +21,15 @@ import (
 var (
 	messengerType string
 	deviceDB      string
+	messageDB     string
+	mediaDir      string
+	uploadDir     string
 	webhookURL    string
 	logLevel      string
+	qrFile        string
+	pairPhone     string
+	accountAlias  string
+	countryCode   string
 )
 
 var rootCmd = &cobra.Command{
@@ -31,11 +39,46 @@ var rootCmd = &cobra.Command{
 	RunE:  run,
 }
 
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Link this device to a WhatsApp account",
+	Long: `Link this device to a WhatsApp account by scanning a QR code, or by entering a pairing code on the phone with --pair-phone.
+Run it again with --account to link further accounts under their own alias.`,
+	Args: cobra.NoArgs,
+	RunE: login,
+}
+
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Unlink a WhatsApp account, deleting the device database once no account is left",
+	Args:  cobra.NoArgs,
+	RunE:  logout,
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the login and connection status of the WhatsApp accounts",
+	Args:  cobra.NoArgs,
+	RunE:  status,
+}
+
 func init() {
 	rootCmd.Flags().StringVar(&messengerType, "messenger", "whatsapp", "Messenger type (whatsapp, teams)")
-	rootCmd.Flags().StringVar(&deviceDB, "device", "device.db", "Device database file path (for WhatsApp)")
+	rootCmd.PersistentFlags().StringVar(&deviceDB, "device", "device.db", "Device database file path (for WhatsApp)")
+	rootCmd.PersistentFlags().StringVar(&messageDB, "message-db", "messages.db", "Message store database file path (for WhatsApp)")
+	rootCmd.PersistentFlags().StringVar(&mediaDir, "media-dir", "media", "Directory for downloaded media (for WhatsApp)")
+	rootCmd.Flags().StringVar(&uploadDir, "upload-dir", "uploads", "Directory tools may send local files from, empty to disallow (for WhatsApp)")
+	rootCmd.PersistentFlags().StringVar(&countryCode, "default-country-code", "", "Country calling code assumed for phone numbers without one, e.g. 351 (for WhatsApp)")
 	rootCmd.Flags().StringVar(&webhookURL, "webhook", "", "Webhook URL (for Teams)")
-	rootCmd.Flags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+
+	loginCmd.Flags().StringVar(&qrFile, "qr-file", "", "Save the QR code as a PNG image at this path instead of printing it")
+	loginCmd.Flags().StringVar(&pairPhone, "pair-phone", "", "Link with a pairing code for this phone number instead of a QR code")
+	loginCmd.MarkFlagsMutuallyExclusive("qr-file", "pair-phone")
+	loginCmd.Flags().StringVar(&accountAlias, "account", "", "Alias of the account to link (defaults to \"default\" for the first account)")
+	logoutCmd.Flags().StringVar(&accountAlias, "account", "", "Alias of the account to unlink (can be left out with a single account)")
+
+	rootCmd.AddCommand(loginCmd, logoutCmd, statusCmd)
 }
 
 func run(cmd *cobra.Command, args []string) error {
@@ -45,6 +88,9 @@ func run(cmd *cobra.Command, args []string) error {
 	log.Info().
 		Str("messenger", messengerType).
 		Str("device_db", deviceDB).
+		Str("message_db", messageDB).
+		Str("media_dir", mediaDir).
+		Str("upload_dir", uploadDir).
 		Str("log_level", logLevel).
 		Msg("Starting MultiChat MCP Server")
 
@@ -53,9 +99,9 @@ func run(cmd *cobra.Command, args []string) error {
 	var err error
 	switch messengerType {
 	case "whatsapp":
-		msg, err = whatsapp.NewWhatsAppMessenger(deviceDB)
+		msg, err = newWhatsAppMessenger()
 		if err != nil {
-			return fmt.Errorf("failed to create WhatsApp messenger: %w", err)
+			return err
 		}
 	case "teams":
 		msg, err = teams.NewTeamsMessenger(webhookURL)
@@ -101,6 +147,90 @@ func run(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func login(cmd *cobra.Command, args []string) error {
+	setupLogger(logLevel)
+
+	wa, err := newWhatsAppMessenger()
+	if err != nil {
+		return err
+	}
+	defer wa.Disconnect()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	return wa.Login(ctx, accountAlias, whatsapp.LoginOptions{QRFile: qrFile, PairPhone: pairPhone})
+}
+
+func logout(cmd *cobra.Command, args []string) error {
+	setupLogger(logLevel)
+
+	wa, err := newWhatsAppMessenger()
+	if err != nil {
+		return err
+	}
+	defer wa.Disconnect()
+
+	return wa.Logout(cmd.Context(), accountAlias)
+}
+
+func status(cmd *cobra.Command, args []string) error {
+	setupLogger(logLevel)
+
+	wa, err := newWhatsAppMessenger()
+	if err != nil {
+		return err
+	}
+	defer wa.Disconnect()
+
+	accounts, err := wa.Status(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	if len(accounts) == 0 {
+		fmt.Println("Logged in: no (run \"multichat login\")")
+		return nil
+	}
+	for i, st := range accounts {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Account:   %s\n", st.Alias)
+		fmt.Printf("JID:       %s\n", st.JID)
+		fmt.Printf("Push name: %s\n", st.PushName)
+		if st.BusinessName != "" {
+			fmt.Printf("Business:  %s\n", st.BusinessName)
+		}
+		// Connection state is recorded by the MCP server, which may be another process
+		if st.Connected {
+			fmt.Println("Connected: yes")
+		} else {
+			fmt.Println("Connected: no")
+		}
+		if st.LastSeen != nil {
+			fmt.Printf("Last seen: %s\n", st.LastSeen.Local().Format(time.RFC1123))
+		} else {
+			fmt.Println("Last seen: never")
+		}
+	}
+	return nil
+}
+
+func newWhatsAppMessenger() (*whatsapp.WhatsAppMessenger, error) {
+	wa, err := whatsapp.NewWhatsAppMessenger(whatsapp.WhatsAppConfig{
+		DeviceDB:           deviceDB,
+		MessageDB:          messageDB,
+		MediaDir:           mediaDir,
+		UploadDir:          uploadDir,
+		DefaultCountryCode: countryCode,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WhatsApp messenger: %w", err)
+	}
+	return wa, nil
+}
+
 func setupLogger(level string) {
 	// Set up zerolog with human-friendly output for development
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix